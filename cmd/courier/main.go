@@ -1,73 +1,52 @@
 package main
 
 import (
-	"context"
-	"flag"
-	"log"
-	"net/http"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-
-	"red-courier/internal/config"
-	"red-courier/internal/db"
-	"red-courier/internal/redis"
-	"red-courier/internal/scheduler"
+	"strings"
 )
 
-func main() {
-	defaultPath := "config.yaml"
-	envPath := os.Getenv("RED_COURIER_CONFIG")
-	if envPath != "" {
-		defaultPath = envPath
-	}
+const usage = `Usage: red-courier [command] [flags]
 
-	cfgPath := flag.String("config", defaultPath, "path to the config file (YAML)")
-	flag.Parse()
+Commands:
+  run          start the scheduler and HTTP server (default)
+  validate     check a config file and report every problem found
+  schema       print the JSON Schema for the config file
+  trigger-sql  print SQL that installs NOTIFY triggers for tasks with a trigger
 
-	cfg, err := config.LoadConfig(*cfgPath)
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
+Run "red-courier <command> -h" for command flags.
+`
 
-	pg, err := db.NewDatabase(*cfg)
-	if err != nil {
-		log.Fatalf("Postgres error: %v", err)
-	}
-	defer pg.Close()
+func main() {
+	args := os.Args[1:]
 
-	rdb := redis.NewRedisClient(redis.RedisConfig(cfg.Redis))
-	if err != nil {
-		log.Fatalf("Redis error: %v", err)
+	// No command (or only flags) keeps the historical behaviour of starting the service.
+	cmd := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
 	}
-	defer rdb.Close()
 
-	ctx := context.Background()
-	sched, err := scheduler.NewScheduler(ctx, cfg, pg, rdb)
-	if err != nil {
-		log.Fatalf("Scheduler setup failed: %v", err)
+	switch cmd {
+	case "run":
+		os.Exit(runCmd(args))
+	case "validate":
+		os.Exit(validateCmd(args))
+	case "schema":
+		os.Exit(schemaCmd(args))
+	case "trigger-sql":
+		os.Exit(triggerSQLCmd(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
 	}
+}
 
-	go sched.Start()
-	port := cfg.Server.Port
-	log.Printf("Server starting on port %s", port)
-
-	if port == "" {
-		port = ":8080"
+// defaultConfigPath returns RED_COURIER_CONFIG when set, otherwise config.yaml.
+func defaultConfigPath() string {
+	if envPath := os.Getenv("RED_COURIER_CONFIG"); envPath != "" {
+		return envPath
 	}
-
-	go func() {
-		mux := http.NewServeMux()
-		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-		})
-		_ = http.ListenAndServe(port, mux)
-	}()
-
-	// Handle graceful shutdown
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	<-sig
-
-	sched.Stop()
+	return "config.yaml"
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"red-courier/internal/config"
+	"red-courier/internal/db"
+	"red-courier/internal/preflight"
+	"red-courier/internal/scheduler"
+)
+
+// reloader re-reads the config file and applies its tasks to the running scheduler.
+// It is triggered by the file watcher, SIGHUP and POST /admin/reload.
+type reloader struct {
+	path  string
+	sched *scheduler.Scheduler
+	db    *db.Database
+
+	mu      sync.Mutex
+	current *config.Config
+}
+
+// reload loads and validates the config file and, only if it is valid, applies it.
+func (r *reloader) reload(reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log.Printf("Reloading config from %s (%s)", r.path, reason)
+	cfg, err := config.LoadConfig(r.path)
+	if err != nil {
+		log.Printf("Reload rejected: %v", err)
+		return err
+	}
+	problems := preflight.CheckConfig(cfg)
+	if len(problems) == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		var warnings []error
+		problems, warnings = preflight.CheckDatabase(ctx, r.db, cfg)
+		cancel()
+		for _, w := range warnings {
+			log.Printf("Reload warning: %v", w)
+		}
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			log.Printf("Reload rejected: %v", p)
+		}
+		return errors.Join(problems...)
+	}
+
+	// Connections are created once at startup; only task changes apply live.
+	if !reflect.DeepEqual(cfg.Postgres, r.current.Postgres) || !reflect.DeepEqual(cfg.Redis, r.current.Redis) ||
+		cfg.Server != r.current.Server || cfg.LogSQL != r.current.LogSQL ||
+		!reflect.DeepEqual(cfg.LeaderElection, r.current.LeaderElection) ||
+		!reflect.DeepEqual(cfg.Scheduler.TaskLocks, r.current.Scheduler.TaskLocks) {
+		log.Printf("Reload: postgres, redis, server, leader_election, scheduler.task_locks and log_sql changes require a restart and were not applied")
+	}
+
+	if err := r.sched.Reload(cfg); err != nil {
+		log.Printf("Reload rejected: %v", err)
+		return err
+	}
+	r.current = cfg
+	return nil
+}
+
+// ServeHTTP handles POST /admin/reload.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.reload("admin endpoint"); err != nil {
+		http.Error(w, fmt.Sprintf("reload rejected: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	_, _ = fmt.Fprintln(w, "reloaded")
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"red-courier/internal/config"
+	"red-courier/internal/db"
+	"red-courier/internal/leader"
+	"red-courier/internal/preflight"
+	"red-courier/internal/redis"
+	"red-courier/internal/scheduler"
+)
+
+func runCmd(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "path to the config file (YAML)")
+	watchInterval := fs.Duration("watch-interval", 5*time.Second, "how often to check the config file for changes (0 disables)")
+	_ = fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if problems := preflight.CheckConfig(cfg); len(problems) > 0 {
+		for _, p := range problems {
+			log.Printf("Invalid config: %v", p)
+		}
+		log.Printf("Refusing to start: %d config problem(s) in %s", len(problems), *cfgPath)
+		return 1
+	}
+
+	pg, err := db.NewDatabase(*cfg)
+	if err != nil {
+		log.Fatalf("Postgres error: %v", err)
+	}
+	defer pg.Close()
+
+	checkCtx, cancelCheck := context.WithTimeout(context.Background(), 30*time.Second)
+	problems, warnings := preflight.CheckDatabase(checkCtx, pg, cfg)
+	cancelCheck()
+	for _, w := range warnings {
+		log.Printf("Warning: %v", w)
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			log.Printf("Invalid config: %v", p)
+		}
+		log.Printf("Refusing to start: %d config problem(s) in %s", len(problems), *cfgPath)
+		return 1
+	}
+
+	rdb := redis.NewRedisClient(redis.RedisConfig(cfg.Redis))
+	defer rdb.Close()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	sched, err := scheduler.NewScheduler(ctx, cfg, pg, rdb)
+	if err != nil {
+		log.Printf("Scheduler setup failed: %v", err)
+		return 1
+	}
+	rl := &reloader{path: *cfgPath, sched: sched, db: pg, current: cfg}
+
+	var elector *leader.Elector
+	electionDone := make(chan struct{})
+	if le := cfg.LeaderElection; le != nil && le.Enabled {
+		elector = leader.New(rdb, le.EffectiveKey(), le.ID, le.EffectiveTTL(), le.EffectiveRenewInterval())
+		log.Printf("Leader election enabled: %s competes for %s", elector.Status().ID, le.EffectiveKey())
+		go func() {
+			defer close(electionDone)
+			elector.Run(ctx, sched.Lead)
+		}()
+	} else {
+		close(electionDone)
+		go sched.Start()
+	}
+	port := cfg.Server.Port
+	log.Printf("Server starting on port %s", port)
+
+	if port == "" {
+		port = ":8080"
+	}
+
+	go func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		mux.Handle("/admin/reload", rl)
+		mux.Handle("/leader", leaderStatus(elector))
+		mux.Handle("/healthz/tasks", taskHealth(sched))
+		_ = http.ListenAndServe(port, mux)
+	}()
+
+	if *watchInterval > 0 {
+		go config.Watch(ctx, *cfgPath, *watchInterval, func() {
+			_ = rl.reload("config file changed")
+		})
+	}
+
+	// Handle reloads and graceful shutdown
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for s := range sig {
+		if s == syscall.SIGHUP {
+			_ = rl.reload("SIGHUP")
+			continue
+		}
+		break
+	}
+
+	if elector != nil {
+		// Stops the scheduler and releases the lease so a follower takes over at once.
+		stop()
+		<-electionDone
+	} else {
+		// Cancels runs in flight and waits for them to finish.
+		stop()
+		sched.Stop()
+	}
+	return 0
+}
+
+// leaderStatus serves the election state as JSON. Without leader election the
+// replica always leads.
+func leaderStatus(el *leader.Elector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := leader.Status{ID: "standalone", Leader: true}
+		if el != nil {
+			status = el.Status()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(status)
+	}
+}
+
+// taskHealth serves the failure state of every task as JSON, with status 503
+// while any task is paused by its circuit breaker.
+func taskHealth(sched *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		health := sched.Health()
+		code := http.StatusOK
+		for _, h := range health {
+			if !h.Healthy {
+				code = http.StatusServiceUnavailable
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(health)
+	}
+}
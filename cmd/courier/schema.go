@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"red-courier/internal/config"
+)
+
+// schemaCmd prints the JSON Schema for config.yaml, or writes it to -o.
+func schemaCmd(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	out := fs.String("o", "", "write the schema to this file instead of stdout")
+	_ = fs.Parse(args)
+
+	b, err := config.JSONSchema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "generate schema: %v\n", err)
+		return 1
+	}
+
+	if *out == "" {
+		_, _ = os.Stdout.Write(b)
+		return 0
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "write schema: %v\n", err)
+		return 1
+	}
+	return 0
+}
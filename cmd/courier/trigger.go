@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"red-courier/internal/config"
+	"red-courier/internal/db"
+)
+
+// triggerSQLCmd prints the SQL that installs NOTIFY triggers for tasks with a trigger,
+// for a DBA to review and apply. Tasks with a query are skipped with a warning, as
+// their source tables are not known; if every task is skipped nothing is printed,
+// and the command still succeeds.
+func triggerSQLCmd(args []string) int {
+	fs := flag.NewFlagSet("trigger-sql", flag.ExitOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "path to the config file (YAML)")
+	name := fs.String("task", "", "only print the trigger for this task")
+	_ = fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *cfgPath, err)
+		return 1
+	}
+
+	// Build every trigger before printing, so a failure never leaves partial SQL.
+	var stmts []string
+	skipped := 0
+	for _, t := range cfg.Tasks {
+		if t.Trigger == nil || (*name != "" && t.Name != *name) {
+			continue
+		}
+		if t.Table == "" {
+			fmt.Fprintf(os.Stderr, "warning: task %q uses query; create triggers on its source tables by hand\n", t.Name)
+			skipped++
+			continue
+		}
+		sql, err := db.TriggerSQL(t)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		stmts = append(stmts, sql)
+	}
+	if len(stmts) == 0 {
+		switch {
+		case skipped > 0:
+			// Nothing is wrong with the config; the warnings say what to do instead.
+			fmt.Fprintf(os.Stderr, "%s: no trigger SQL generated: every matching trigger task uses query\n", *cfgPath)
+			return 0
+		case *name != "":
+			fmt.Fprintf(os.Stderr, "%s: no task %q with a trigger\n", *cfgPath, *name)
+		default:
+			fmt.Fprintf(os.Stderr, "%s: no tasks with a trigger\n", *cfgPath)
+		}
+		return 1
+	}
+	fmt.Print(strings.Join(stmts, "\n"))
+	return 0
+}
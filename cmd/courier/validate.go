@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"red-courier/internal/config"
+	"red-courier/internal/db"
+	"red-courier/internal/preflight"
+)
+
+// validateCmd loads and checks a config file. By default it does not connect to Postgres
+// or Redis; with -db it also checks every task's table, columns and query against Postgres.
+// It exits non-zero when any problem is found so it can gate CI.
+func validateCmd(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	cfgPath := fs.String("config", defaultConfigPath(), "path to the config file (YAML)")
+	checkDB := fs.Bool("db", false, "also check tables, columns and queries against Postgres")
+	_ = fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *cfgPath, err)
+		return 1
+	}
+
+	problems := preflight.CheckConfig(cfg)
+	if len(problems) == 0 && *checkDB {
+		pg, err := db.NewDatabase(*cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *cfgPath, err)
+			return 1
+		}
+		defer pg.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		var warnings []error
+		problems, warnings = preflight.CheckDatabase(ctx, pg, cfg)
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "%s: warning: %v\n", *cfgPath, w)
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d problem(s) found:\n", *cfgPath, len(problems))
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "  - %v\n", p)
+		}
+		return 1
+	}
+
+	fmt.Printf("%s: OK (%d tasks)\n", *cfgPath, len(cfg.Tasks))
+	return 0
+}
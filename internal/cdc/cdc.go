@@ -0,0 +1,340 @@
+// Package cdc streams a table's changes from a Postgres logical replication slot
+// (pgoutput) into Redis, as an alternative to polling the table on a schedule.
+package cdc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/config"
+	"red-courier/internal/redis"
+	"red-courier/internal/redis/loader"
+	sqlbuilder "red-courier/internal/sql_builder"
+)
+
+const (
+	retryMin = time.Second
+	retryMax = 30 * time.Second
+
+	// statusInterval is how often the consumer reports its position to Postgres,
+	// well inside the default wal_sender_timeout of one minute.
+	statusInterval = 10 * time.Second
+)
+
+// Run streams the changes of cfg's table into Redis until ctx is cancelled,
+// reconnecting with backoff whenever the replication connection fails. connCfg
+// is the regular connection config; Run opens its own replication connection.
+//
+// Each transaction's changes are written in script calls of at most batch_size
+// changes, and the last call also stores the commit LSN at cdc.lsn_key. On restart
+// streaming resumes from that LSN and transactions at or before it are skipped,
+// so a change is applied again only if the service stopped mid-transaction.
+func Run(ctx context.Context, connCfg *pgconn.Config, cfg config.TaskConfig, r *redis.RedisClient) error {
+	w, err := loader.NewChangeWriter(cfg)
+	if err != nil {
+		return err
+	}
+	schema, table := sqlbuilder.SplitSchemaTable(cfg.Table)
+	c := &consumer{
+		cfg:     cfg,
+		writer:  w,
+		redis:   r,
+		schema:  sqlbuilder.PgName(schema),
+		table:   sqlbuilder.PgName(table),
+		typeMap: pgtype.NewMap(),
+	}
+
+	retry := retryMin
+	for ctx.Err() == nil {
+		streamed, err := c.session(ctx, connCfg)
+		if ctx.Err() != nil {
+			break
+		}
+		if streamed {
+			retry = retryMin
+		}
+		log.Printf("Replication for task %s stopped, retrying in %s: %v", cfg.Name, retry, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, retryMax)
+	}
+	return nil
+}
+
+type consumer struct {
+	cfg     config.TaskConfig
+	writer  *loader.ChangeWriter
+	redis   *redis.RedisClient
+	schema  string
+	table   string
+	typeMap *pgtype.Map
+
+	relations map[uint32]*pglogrepl.RelationMessage
+	applied   pglogrepl.LSN // commit LSN of the last transaction stored in Redis
+	confirmed pglogrepl.LSN // WAL position reported to Postgres as flushed
+	inTx      bool
+	skipTx    bool // the current transaction was applied before a restart
+	txChanges int  // changes of the current transaction, written or pending
+	pending   []loader.Change
+}
+
+// session streams from one replication connection until it fails, reporting
+// whether it got as far as starting replication.
+func (c *consumer) session(ctx context.Context, connCfg *pgconn.Config) (bool, error) {
+	if err := c.loadApplied(ctx); err != nil {
+		return false, err
+	}
+
+	replCfg := connCfg.Copy()
+	if replCfg.RuntimeParams == nil {
+		replCfg.RuntimeParams = make(map[string]string)
+	}
+	replCfg.RuntimeParams["replication"] = "database"
+	conn, err := pgconn.ConnectConfig(ctx, replCfg)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if err := c.ensureSlot(ctx, conn); err != nil {
+		return false, err
+	}
+	err = pglogrepl.StartReplication(ctx, conn, c.cfg.CDC.Slot, c.applied, pglogrepl.StartReplicationOptions{
+		PluginArgs: []string{"proto_version '1'", fmt.Sprintf("publication_names '%s'", c.cfg.CDC.Publication)},
+	})
+	if err != nil {
+		return false, fmt.Errorf("start replication on slot %s: %w", c.cfg.CDC.Slot, err)
+	}
+	log.Printf("Streaming %s.%s for task %s from slot %s at %s", c.schema, c.table, c.cfg.Name, c.cfg.CDC.Slot, c.applied)
+
+	c.relations = make(map[uint32]*pglogrepl.RelationMessage)
+	c.inTx, c.pending = false, nil
+	c.confirmed = c.applied
+	nextStatus := time.Now()
+	for {
+		if !time.Now().Before(nextStatus) {
+			if err := c.sendStatus(ctx, conn); err != nil {
+				return true, err
+			}
+			nextStatus = time.Now().Add(statusInterval)
+		}
+
+		recvCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		msg, err := conn.ReceiveMessage(recvCtx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) && ctx.Err() == nil {
+				continue
+			}
+			return true, err
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.ErrorResponse:
+			return true, pgconn.ErrorResponseToPgError(msg)
+		case *pgproto3.CopyData:
+			if len(msg.Data) == 0 {
+				continue
+			}
+			switch msg.Data[0] {
+			case pglogrepl.PrimaryKeepaliveMessageByteID:
+				ka, err := pglogrepl.ParsePrimaryKeepaliveMessage(msg.Data[1:])
+				if err != nil {
+					return true, err
+				}
+				// Between transactions everything the server has sent is in Redis,
+				// so the slot can move up to the server's position.
+				if !c.inTx && ka.ServerWALEnd > c.confirmed {
+					c.confirmed = ka.ServerWALEnd
+				}
+				if ka.ReplyRequested {
+					nextStatus = time.Now()
+				}
+			case pglogrepl.XLogDataByteID:
+				xld, err := pglogrepl.ParseXLogData(msg.Data[1:])
+				if err != nil {
+					return true, err
+				}
+				committed, err := c.handle(ctx, xld.WALData)
+				if err != nil {
+					return true, err
+				}
+				if committed {
+					nextStatus = time.Now()
+				}
+			}
+		}
+	}
+}
+
+// loadApplied reads the last applied commit LSN from Redis.
+func (c *consumer) loadApplied(ctx context.Context) error {
+	s, err := c.redis.GetString(ctx, c.cfg.CDC.LSNKey)
+	if errors.Is(err, goredis.Nil) {
+		c.applied = 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", c.cfg.CDC.LSNKey, err)
+	}
+	c.applied, err = pglogrepl.ParseLSN(s)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", c.cfg.CDC.LSNKey, err)
+	}
+	return nil
+}
+
+// ensureSlot creates the replication slot unless it exists. A new slot starts at
+// the current WAL position: rows already in the table are not copied.
+func (c *consumer) ensureSlot(ctx context.Context, conn *pgconn.PgConn) error {
+	// The slot name is validated as a plain identifier, so it can be inlined.
+	res, err := conn.Exec(ctx, fmt.Sprintf("SELECT 1 FROM pg_replication_slots WHERE slot_name = '%s'", c.cfg.CDC.Slot)).ReadAll()
+	if err != nil {
+		return fmt.Errorf("look up slot %s: %w", c.cfg.CDC.Slot, err)
+	}
+	if len(res) > 0 && len(res[0].Rows) > 0 {
+		return nil
+	}
+	if _, err := pglogrepl.CreateReplicationSlot(ctx, conn, c.cfg.CDC.Slot, "pgoutput",
+		pglogrepl.CreateReplicationSlotOptions{Mode: pglogrepl.LogicalReplication}); err != nil {
+		return fmt.Errorf("create slot %s: %w", c.cfg.CDC.Slot, err)
+	}
+	log.Printf("Created replication slot %s for task %s", c.cfg.CDC.Slot, c.cfg.Name)
+	return nil
+}
+
+func (c *consumer) sendStatus(ctx context.Context, conn *pgconn.PgConn) error {
+	return pglogrepl.SendStandbyStatusUpdate(ctx, conn, pglogrepl.StandbyStatusUpdate{WALWritePosition: c.confirmed})
+}
+
+// handle processes one pgoutput message, reporting whether it committed a
+// transaction that touched the table.
+func (c *consumer) handle(ctx context.Context, data []byte) (bool, error) {
+	msg, err := pglogrepl.Parse(data)
+	if err != nil {
+		return false, fmt.Errorf("parse replication message: %w", err)
+	}
+
+	switch m := msg.(type) {
+	case *pglogrepl.RelationMessage:
+		c.relations[m.RelationID] = m
+
+	case *pglogrepl.BeginMessage:
+		c.inTx = true
+		c.skipTx = c.applied > 0 && m.FinalLSN <= c.applied
+		c.txChanges = 0
+		c.pending = c.pending[:0]
+
+	case *pglogrepl.InsertMessage:
+		if rel := c.relation(m.RelationID); rel != nil {
+			return false, c.add(ctx, loader.Change{Op: loader.OpInsert, Row: c.decode(rel, m.Tuple, nil)})
+		}
+
+	case *pglogrepl.UpdateMessage:
+		if rel := c.relation(m.RelationID); rel != nil {
+			old := c.decode(rel, m.OldTuple, nil)
+			return false, c.add(ctx, loader.Change{Op: loader.OpUpdate, Row: c.decode(rel, m.NewTuple, old), Old: old})
+		}
+
+	case *pglogrepl.DeleteMessage:
+		if rel := c.relation(m.RelationID); rel != nil {
+			return false, c.add(ctx, loader.Change{Op: loader.OpDelete, Row: c.decode(rel, m.OldTuple, nil)})
+		}
+
+	case *pglogrepl.TruncateMessage:
+		for _, id := range m.RelationIDs {
+			if c.relation(id) != nil {
+				return false, c.add(ctx, loader.Change{Op: loader.OpTruncate})
+			}
+		}
+
+	case *pglogrepl.CommitMessage:
+		c.inTx = false
+		committed := !c.skipTx && c.txChanges > 0
+		if committed {
+			if err := c.writer.Apply(ctx, c.redis, c.pending, m.CommitLSN.String()); err != nil {
+				return false, fmt.Errorf("apply transaction %s: %w", m.CommitLSN, err)
+			}
+			c.applied = m.CommitLSN
+		}
+		c.pending = c.pending[:0]
+		c.confirmed = max(c.confirmed, m.TransactionEndLSN)
+		return committed, nil
+	}
+	return false, nil
+}
+
+// relation returns the relation when it is the task's table and the current
+// transaction still needs applying.
+func (c *consumer) relation(id uint32) *pglogrepl.RelationMessage {
+	if c.skipTx {
+		return nil
+	}
+	rel, ok := c.relations[id]
+	if !ok || rel.Namespace != c.schema || rel.RelationName != c.table {
+		return nil
+	}
+	return rel
+}
+
+// add queues a change, writing the queue out (without an LSN) once it holds a
+// full batch so large transactions are not buffered whole.
+func (c *consumer) add(ctx context.Context, change loader.Change) error {
+	c.txChanges++
+	c.pending = append(c.pending, change)
+	if len(c.pending) < c.cfg.EffectiveBatchSize() {
+		return nil
+	}
+	if err := c.writer.Apply(ctx, c.redis, c.pending, ""); err != nil {
+		return err
+	}
+	c.pending = c.pending[:0]
+	return nil
+}
+
+// decode converts a tuple to a row keyed by column name. Unchanged TOAST values
+// are not sent by Postgres; they are taken from fallback when it has them.
+func (c *consumer) decode(rel *pglogrepl.RelationMessage, tuple *pglogrepl.TupleData, fallback map[string]any) map[string]any {
+	if tuple == nil {
+		return nil
+	}
+	row := make(map[string]any, len(tuple.Columns))
+	for i, col := range tuple.Columns {
+		if i >= len(rel.Columns) {
+			break
+		}
+		name := rel.Columns[i].Name
+		switch col.DataType {
+		case pglogrepl.TupleDataTypeNull:
+			row[name] = nil
+		case pglogrepl.TupleDataTypeText:
+			row[name] = c.decodeText(rel.Columns[i].DataType, col.Data)
+		case pglogrepl.TupleDataTypeToast:
+			if v, ok := fallback[name]; ok {
+				row[name] = v
+			}
+		}
+	}
+	return row
+}
+
+// decodeText parses a text-format value into the Go type pgx would return for
+// the column, falling back to the text itself for unknown types.
+func (c *consumer) decodeText(oid uint32, data []byte) any {
+	if dt, ok := c.typeMap.TypeForOID(oid); ok {
+		if v, err := dt.Codec.DecodeValue(c.typeMap, oid, pgtype.TextFormatCode, data); err == nil {
+			return v
+		}
+	}
+	return string(data)
+}
@@ -0,0 +1,101 @@
+package cdc
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5"
+	"red-courier/internal/config"
+	"red-courier/internal/redis"
+)
+
+// TestRun_Postgres streams changes from a real server. It needs a Postgres with
+// wal_level = logical and a role allowed to create replication slots, e.g.
+//
+//	RED_COURIER_TEST_PG_DSN=postgres://postgres@localhost:5432/postgres go test ./internal/cdc
+func TestRun_Postgres(t *testing.T) {
+	dsn := os.Getenv("RED_COURIER_TEST_PG_DSN")
+	if dsn == "" {
+		t.Skip("RED_COURIER_TEST_PG_DSN not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close(context.Background())
+
+	exec := func(sql string) {
+		t.Helper()
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	exec(`DROP TABLE IF EXISTS red_courier_cdc_test`)
+	exec(`CREATE TABLE red_courier_cdc_test (id bigint PRIMARY KEY, status text NOT NULL)`)
+	exec(`DROP PUBLICATION IF EXISTS red_courier_cdc_test`)
+	exec(`CREATE PUBLICATION red_courier_cdc_test FOR TABLE red_courier_cdc_test`)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_, _ = conn.Exec(ctx, `SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = 'red_courier_cdc_test'`)
+		_, _ = conn.Exec(ctx, `DROP PUBLICATION IF EXISTS red_courier_cdc_test`)
+		_, _ = conn.Exec(ctx, `DROP TABLE IF EXISTS red_courier_cdc_test`)
+	})
+
+	mr := miniredis.RunT(t)
+	r := redis.NewRedisClient(redis.RedisConfig{Addr: mr.Addr()})
+	defer r.Close()
+
+	cfg := config.TaskConfig{
+		Name:      "cdc_test",
+		Table:     "public.red_courier_cdc_test",
+		Alias:     "cdc_test",
+		Structure: "map",
+		Key:       "id",
+		Value:     "status",
+		CDC:       &config.CDCConfig{Slot: "red_courier_cdc_test", Publication: "red_courier_cdc_test", LSNKey: "lsn:cdc_test"},
+	}
+	streamCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- Run(streamCtx, &conn.Config().Config, cfg, r) }()
+
+	// The slot is created by Run; wait for it so the changes below are captured.
+	waitFor(t, func() bool {
+		var n int
+		_ = conn.QueryRow(ctx, `SELECT count(*) FROM pg_replication_slots WHERE slot_name = 'red_courier_cdc_test'`).Scan(&n)
+		return n == 1
+	})
+
+	exec(`INSERT INTO red_courier_cdc_test VALUES (1, 'NEW'), (2, 'NEW')`)
+	exec(`UPDATE red_courier_cdc_test SET status = 'PAID' WHERE id = 1`)
+	exec(`DELETE FROM red_courier_cdc_test WHERE id = 2`)
+
+	waitFor(t, func() bool {
+		keys, _ := mr.HKeys("cdc_test")
+		return len(keys) == 1 && mr.HGet("cdc_test", "1") == "PAID"
+	})
+	if !mr.Exists("lsn:cdc_test") {
+		t.Errorf("expected the commit LSN to be stored")
+	}
+
+	stop()
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(20 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in time")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
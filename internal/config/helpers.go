@@ -1,8 +1,261 @@
 package config
 
+import (
+	"strings"
+	"time"
+
+	"red-courier/internal/pgvalue"
+)
+
+// DefaultBatchSize is the number of rows written per Redis pipeline when batch_size is not set.
+const DefaultBatchSize = 500
+
+// MaxBatchSize bounds batch_size so a checkpointed batch fits in a single Lua call.
+const MaxBatchSize = 2000
+
+// DefaultMaxDeleteRatio is used by mode "replace" when max_delete_ratio is not set.
+const DefaultMaxDeleteRatio = 0.5
+
+// DefaultTriggerDebounce is used when trigger.debounce is not set.
+const DefaultTriggerDebounce = time.Second
+
+// DefaultTaskTimeout is used when a task's timeout is not set.
+const DefaultTaskTimeout = time.Minute
+
+// PoolMaxConns is the size of the Postgres connection pool shared by all tasks.
+const PoolMaxConns = 10
+
+// DefaultMaxConcurrentTasks leaves pool connections free for the checks a config
+// reload runs against Postgres while tasks are running, when
+// scheduler.max_concurrent_tasks is not set. The NOTIFY listener and CDC streams
+// open their own connections outside the pool.
+const DefaultMaxConcurrentTasks = PoolMaxConns - 2
+
+// Overlap policies: what a task does when it is due while its previous run is
+// still in flight.
+const (
+	OverlapSkip  = "skip"  // drop the new run (default)
+	OverlapQueue = "queue" // run once more when the current run finishes
+	OverlapAllow = "allow" // run concurrently
+)
+
+// Overlaps lists the supported values for TaskConfig.Overlap.
+var Overlaps = []string{OverlapSkip, OverlapQueue, OverlapAllow}
+
+// Leader election defaults.
+const (
+	DefaultLeaderKey = "red-courier:leader"
+	DefaultLeaderTTL = 15 * time.Second
+)
+
+// Retry and circuit breaker defaults.
+const (
+	DefaultRetryAttempts    = 3
+	DefaultRetryBackoff     = time.Second
+	DefaultRetryMaxBackoff  = 30 * time.Second
+	DefaultFailureThreshold = 5
+	DefaultBreakerPause     = 10 * time.Minute
+)
+
+// Task lock defaults.
+const (
+	DefaultLockKeyPrefix = "red-courier:lock:"
+	DefaultLockTTL       = 30 * time.Second
+)
+
+// DefaultDedupWindow is used when stream.dedup.window is not set.
+const DefaultDedupWindow = 24 * time.Hour
+
 func (t TaskConfig) EffectiveLogSQL(appDefault bool) bool {
 	if t.LogSQL != nil {
 		return *t.LogSQL
 	}
 	return appDefault
 }
+
+// DataStructure returns the Redis structure the task's rows are written as.
+// For snapshot tasks this is the configured snapshot structure (default "stream").
+func (t TaskConfig) DataStructure() string {
+	if t.Structure != "snapshot" {
+		return t.Structure
+	}
+	if t.Snapshot != nil && t.Snapshot.Structure != "" {
+		return t.Snapshot.Structure
+	}
+	return "stream"
+}
+
+// EffectiveMaxDeleteRatio returns the configured max_delete_ratio or DefaultMaxDeleteRatio.
+func (t TaskConfig) EffectiveMaxDeleteRatio() float64 {
+	if t.MaxDeleteRatio != nil {
+		return *t.MaxDeleteRatio
+	}
+	return DefaultMaxDeleteRatio
+}
+
+// EffectiveOverlap returns the configured overlap policy or OverlapSkip.
+func (t TaskConfig) EffectiveOverlap() string {
+	if t.Overlap != "" {
+		return t.Overlap
+	}
+	return OverlapSkip
+}
+
+// EffectiveTimeout returns the configured timeout or DefaultTaskTimeout.
+// An unparsable value is rejected by Validate.
+func (t TaskConfig) EffectiveTimeout() time.Duration {
+	if d, err := time.ParseDuration(t.Timeout); err == nil {
+		return d
+	}
+	return DefaultTaskTimeout
+}
+
+// JitterDuration returns jitter, or 0 when scheduled runs start on time.
+func (t TaskConfig) JitterDuration() time.Duration {
+	d, _ := time.ParseDuration(t.Jitter)
+	return d
+}
+
+// CronSpec returns the schedule for robfig/cron, prefixed with CRON_TZ when a
+// timezone is set. @every intervals do not depend on the zone and are left as is.
+func (t TaskConfig) CronSpec() string {
+	if t.Timezone == "" || strings.HasPrefix(t.Schedule, "@every ") {
+		return t.Schedule
+	}
+	return "CRON_TZ=" + t.Timezone + " " + t.Schedule
+}
+
+// EffectiveMaxConcurrentTasks returns the configured cap or DefaultMaxConcurrentTasks.
+func (s SchedulerConfig) EffectiveMaxConcurrentTasks() int {
+	if s.MaxConcurrentTasks > 0 {
+		return s.MaxConcurrentTasks
+	}
+	return DefaultMaxConcurrentTasks
+}
+
+// EffectiveBatchSize returns the configured batch_size or DefaultBatchSize.
+func (t TaskConfig) EffectiveBatchSize() int {
+	if t.BatchSize > 0 {
+		return t.BatchSize
+	}
+	return DefaultBatchSize
+}
+
+// EffectiveDebounce returns the configured debounce or DefaultTriggerDebounce.
+// An unparsable value is rejected by Validate.
+func (t TriggerConfig) EffectiveDebounce() time.Duration {
+	if d, err := time.ParseDuration(t.Debounce); err == nil {
+		return d
+	}
+	return DefaultTriggerDebounce
+}
+
+// EffectiveWindow returns the configured window or DefaultDedupWindow.
+// An unparsable value is rejected by Validate.
+func (d DedupConfig) EffectiveWindow() time.Duration {
+	if w, err := time.ParseDuration(d.Window); err == nil {
+		return w
+	}
+	return DefaultDedupWindow
+}
+
+// MaxAgeDuration returns max_age, or 0 when entries are not trimmed by age.
+func (s StreamConfig) MaxAgeDuration() time.Duration {
+	d, _ := time.ParseDuration(s.MaxAge)
+	return d
+}
+
+// EffectiveTTL returns the configured lease ttl or DefaultLeaderTTL.
+// An unparsable value is rejected by Validate.
+func (l LeaderElectionConfig) EffectiveTTL() time.Duration {
+	if d, err := time.ParseDuration(l.TTL); err == nil {
+		return d
+	}
+	return DefaultLeaderTTL
+}
+
+// EffectiveRenewInterval returns the configured renew_interval or a third of the ttl.
+func (l LeaderElectionConfig) EffectiveRenewInterval() time.Duration {
+	if d, err := time.ParseDuration(l.RenewInterval); err == nil {
+		return d
+	}
+	return l.EffectiveTTL() / 3
+}
+
+// EffectiveKey returns the configured lease key or DefaultLeaderKey.
+func (l LeaderElectionConfig) EffectiveKey() string {
+	if l.Key != "" {
+		return l.Key
+	}
+	return DefaultLeaderKey
+}
+
+// EffectiveMaxAttempts returns the configured max_attempts or DefaultRetryAttempts.
+func (r RetryConfig) EffectiveMaxAttempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+	return DefaultRetryAttempts
+}
+
+// EffectiveInitialBackoff returns the configured initial_backoff or DefaultRetryBackoff.
+// An unparsable value is rejected by Validate.
+func (r RetryConfig) EffectiveInitialBackoff() time.Duration {
+	if d, err := time.ParseDuration(r.InitialBackoff); err == nil {
+		return d
+	}
+	return DefaultRetryBackoff
+}
+
+// EffectiveMaxBackoff returns the configured max_backoff or DefaultRetryMaxBackoff.
+// An unparsable value is rejected by Validate.
+func (r RetryConfig) EffectiveMaxBackoff() time.Duration {
+	if d, err := time.ParseDuration(r.MaxBackoff); err == nil {
+		return d
+	}
+	return DefaultRetryMaxBackoff
+}
+
+// EffectiveFailureThreshold returns the configured failure_threshold or DefaultFailureThreshold.
+func (c CircuitBreakerConfig) EffectiveFailureThreshold() int {
+	if c.FailureThreshold > 0 {
+		return c.FailureThreshold
+	}
+	return DefaultFailureThreshold
+}
+
+// EffectivePause returns the configured pause or DefaultBreakerPause.
+// An unparsable value is rejected by Validate.
+func (c CircuitBreakerConfig) EffectivePause() time.Duration {
+	if d, err := time.ParseDuration(c.Pause); err == nil {
+		return d
+	}
+	return DefaultBreakerPause
+}
+
+// EffectiveTTL returns the configured lock ttl or DefaultLockTTL.
+// An unparsable value is rejected by Validate.
+func (l TaskLocksConfig) EffectiveTTL() time.Duration {
+	if d, err := time.ParseDuration(l.TTL); err == nil {
+		return d
+	}
+	return DefaultLockTTL
+}
+
+// EffectiveKeyPrefix returns the configured key_prefix or DefaultLockKeyPrefix.
+func (l TaskLocksConfig) EffectiveKeyPrefix() string {
+	if l.KeyPrefix != "" {
+		return l.KeyPrefix
+	}
+	return DefaultLockKeyPrefix
+}
+
+// Encoder returns the value encoder for the task's encoding settings, or
+// pgvalue.Default when it has none.
+func (t TaskConfig) Encoder() (*pgvalue.Encoder, error) {
+	if t.Encoding == nil {
+		return pgvalue.Default, nil
+	}
+	e := t.Encoding
+	return pgvalue.New(e.TimeFormat, e.Timezone, e.Nulls, e.NullValue, e.BoolFormat)
+}
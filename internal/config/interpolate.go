@@ -0,0 +1,132 @@
+// internal/config/interpolate.go
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// interpolator expands references in YAML scalar values before they are decoded:
+//
+//	${VAR}            value of environment variable VAR (error if unset)
+//	${VAR:-default}   value of VAR, or default when VAR is unset or empty
+//	${file:/path}     contents of a file, e.g. a mounted Kubernetes secret;
+//	                  relative paths resolve against the config file directory
+//	$${               a literal "${"
+//
+// Expansion happens on the YAML node tree rather than the raw text, so a
+// secret containing YAML syntax can never change the document structure.
+type interpolator struct {
+	baseDir  string
+	lookup   func(string) (string, bool)
+	readFile func(string) ([]byte, error)
+}
+
+func newInterpolator(configPath string) *interpolator {
+	return &interpolator{
+		baseDir:  filepath.Dir(configPath),
+		lookup:   os.LookupEnv,
+		readFile: os.ReadFile,
+	}
+}
+
+// expandNode walks the node tree and expands every scalar value in place.
+// Mapping keys are left untouched. All failures are returned together.
+func (ip *interpolator) expandNode(n *yaml.Node) error {
+	var errs []error
+	ip.walk(n, &errs)
+	return errors.Join(errs...)
+}
+
+func (ip *interpolator) walk(n *yaml.Node, errs *[]error) {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			ip.walk(c, errs)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			ip.walk(n.Content[i], errs)
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "${") {
+			return
+		}
+		out, err := ip.expand(n.Value)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("line %d: %w", n.Line, err))
+			return
+		}
+		n.Value = out
+		// Let plain scalars re-resolve their type so "port: ${PG_PORT}" decodes as an int,
+		// but never turn a non-empty secret such as "null" or "~" into a null value.
+		if n.Style == 0 {
+			n.Tag = ""
+			if n.ShortTag() == "!!null" && n.Value != "" {
+				n.Tag = "!!str"
+			}
+		}
+	}
+}
+
+func (ip *interpolator) expand(s string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		// "$${" escapes a literal "${"
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", s)
+		}
+		val, err := ip.resolve(s[i+2 : i+end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s[:i])
+		b.WriteString(val)
+		s = s[i+end+1:]
+	}
+}
+
+func (ip *interpolator) resolve(ref string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		if path == "" {
+			return "", fmt.Errorf("empty file reference")
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ip.baseDir, path)
+		}
+		data, err := ip.readFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read referenced file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	name, def, hasDef := strings.Cut(ref, ":-")
+	if name == "" {
+		return "", fmt.Errorf("empty variable name in ${%s}", ref)
+	}
+	if val, ok := ip.lookup(name); ok && (val != "" || !hasDef) {
+		return val, nil
+	}
+	if hasDef {
+		return def, nil
+	}
+	return "", fmt.Errorf("environment variable %s is not set", name)
+}
@@ -0,0 +1,91 @@
+// internal/config/interpolate_test.go
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_ExpandsEnvAndFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "redis_password"), []byte("s3cr3t: {x}\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("PG_HOST", "db.internal")
+	t.Setenv("PG_PORT", "6543")
+	t.Setenv("PG_PASSWORD", "p@ss")
+
+	path := filepath.Join(dir, "config.yaml")
+	yaml := `
+postgres:
+  host: ${PG_HOST}
+  port: ${PG_PORT}
+  password: "${PG_PASSWORD}"
+  sslmode: ${PG_SSLMODE:-disable}
+redis:
+  addr: "${REDIS_HOST:-localhost}:6379"
+  password: ${file:redis_password}
+tasks:
+  - name: orders_stream
+    table: public.orders
+    fields: [id, created_at]
+    where: "note <> '$${literal}'"
+    schedule: "@every 10s"
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	checks := []struct{ name, got, want string }{
+		{"postgres.host", cfg.Postgres.Host, "db.internal"},
+		{"postgres.password", cfg.Postgres.Password, "p@ss"},
+		{"postgres.sslmode", cfg.Postgres.SSLMode, "disable"},
+		{"redis.addr", cfg.Redis.Addr, "localhost:6379"},
+		{"redis.password", cfg.Redis.Password, "s3cr3t: {x}"},
+		{"tasks[0].where", cfg.Tasks[0].Where, "note <> '${literal}'"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %q want %q", c.name, c.got, c.want)
+		}
+	}
+	if cfg.Postgres.Port != 6543 {
+		t.Errorf("postgres.port: got %d want 6543", cfg.Postgres.Port)
+	}
+}
+
+func TestLoadConfig_MissingReferencesFail(t *testing.T) {
+	path := writeTempYAML(t, `
+postgres:
+  password: ${RED_COURIER_TEST_UNSET_VAR}
+redis:
+  password: ${file:/nonexistent/red-courier/secret}
+`)
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatalf("expected error for missing references")
+	}
+	for _, want := range []string{"RED_COURIER_TEST_UNSET_VAR is not set", "read referenced file"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error, got: %v", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_EmptyFile(t *testing.T) {
+	path := writeTempYAML(t, "")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if len(cfg.Tasks) != 0 {
+		t.Fatalf("expected no tasks, got %d", len(cfg.Tasks))
+	}
+}
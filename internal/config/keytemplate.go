@@ -0,0 +1,100 @@
+// internal/config/keytemplate.go
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyTemplate is a parsed Redis key template such as "customer:{region}:{customer_id}".
+// Placeholders name logical fields, which are resolved through column_map.
+type KeyTemplate struct {
+	parts []templatePart
+}
+
+type templatePart struct {
+	literal string
+	field   string // set for placeholders
+}
+
+// ParseKeyTemplate parses a key template. Literal braces are not supported.
+func ParseKeyTemplate(s string) (KeyTemplate, error) {
+	var kt KeyTemplate
+	rest := s
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		close := strings.IndexByte(rest, '}')
+		if open < 0 {
+			if close >= 0 {
+				return KeyTemplate{}, fmt.Errorf("unmatched '}' in key template %q", s)
+			}
+			kt.parts = append(kt.parts, templatePart{literal: rest})
+			break
+		}
+		if close >= 0 && close < open {
+			return KeyTemplate{}, fmt.Errorf("unmatched '}' in key template %q", s)
+		}
+		if open > 0 {
+			kt.parts = append(kt.parts, templatePart{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return KeyTemplate{}, fmt.Errorf("unterminated placeholder in key template %q", s)
+		}
+		field := strings.TrimSpace(rest[open+1 : open+end])
+		if field == "" || strings.ContainsRune(field, '{') {
+			return KeyTemplate{}, fmt.Errorf("invalid placeholder in key template %q", s)
+		}
+		kt.parts = append(kt.parts, templatePart{field: field})
+		rest = rest[open+end+1:]
+	}
+	if len(kt.Fields()) == 0 {
+		return KeyTemplate{}, fmt.Errorf("key template %q has no {field} placeholder", s)
+	}
+	return kt, nil
+}
+
+// Fields returns the logical field names referenced by the template, in order.
+func (kt KeyTemplate) Fields() []string {
+	var out []string
+	for _, p := range kt.parts {
+		if p.field != "" {
+			out = append(out, p.field)
+		}
+	}
+	return out
+}
+
+// Render builds a key, looking up each placeholder with value. It returns
+// false if any placeholder has no value.
+func (kt KeyTemplate) Render(value func(field string) (string, bool)) (string, bool) {
+	var b strings.Builder
+	for _, p := range kt.parts {
+		if p.field == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		v, ok := value(p.field)
+		if !ok {
+			return "", false
+		}
+		b.WriteString(v)
+	}
+	return b.String(), true
+}
+
+// EffectiveKeyTemplate returns the key_template of a "row" task, or builds
+// "<key_prefix>:{<key>}" from key_prefix and key when no template is set.
+func (t TaskConfig) EffectiveKeyTemplate() string {
+	if t.KeyTemplate != "" {
+		return t.KeyTemplate
+	}
+	if t.Key == "" {
+		return ""
+	}
+	prefix := t.KeyPrefix
+	if prefix != "" && !strings.HasSuffix(prefix, ":") {
+		prefix += ":"
+	}
+	return prefix + "{" + t.Key + "}"
+}
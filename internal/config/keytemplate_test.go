@@ -0,0 +1,64 @@
+// internal/config/keytemplate_test.go
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		fields  []string
+		want    string
+		wantErr bool
+	}{
+		{tmpl: "customer:{region}:{customer_id}", fields: []string{"region", "customer_id"}, want: "customer:eu:42"},
+		{tmpl: "{customer_id}", fields: []string{"customer_id"}, want: "42"},
+		{tmpl: "customer:{ region }", fields: []string{"region"}, want: "customer:eu"},
+		{tmpl: "customer", wantErr: true},
+		{tmpl: "customer:{}", wantErr: true},
+		{tmpl: "customer:{id", wantErr: true},
+		{tmpl: "customer:id}", wantErr: true},
+	}
+	values := map[string]string{"region": "eu", "customer_id": "42"}
+
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			kt, err := ParseKeyTemplate(tt.tmpl)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(kt.Fields(), tt.fields) {
+				t.Errorf("fields: got %v want %v", kt.Fields(), tt.fields)
+			}
+			got, ok := kt.Render(func(f string) (string, bool) { v, ok := values[f]; return v, ok })
+			if !ok || got != tt.want {
+				t.Errorf("render: got %q,%v want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveKeyTemplate_FromKeyPrefix(t *testing.T) {
+	tests := []struct {
+		task TaskConfig
+		want string
+	}{
+		{TaskConfig{KeyPrefix: "order", Key: "id"}, "order:{id}"},
+		{TaskConfig{KeyPrefix: "order:", Key: "id"}, "order:{id}"},
+		{TaskConfig{KeyPrefix: "order", Key: "id", KeyTemplate: "o:{region}:{id}"}, "o:{region}:{id}"},
+		{TaskConfig{KeyPrefix: "order"}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.task.EffectiveKeyTemplate(); got != tt.want {
+			t.Errorf("%+v: got %q want %q", tt.task, got, tt.want)
+		}
+	}
+}
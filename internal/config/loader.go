@@ -2,8 +2,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,8 +16,23 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("read config file: %w", err)
 	}
 
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %w", err)
+	}
+
+	// Expand ${VAR}, ${VAR:-default} and ${file:/path} references
+	if err := newInterpolator(path).expandNode(&root); err != nil {
+		return nil, fmt.Errorf("interpolate config: %w", err)
+	}
+
+	// Reject keys that do not map onto a config field instead of silently ignoring them
+	if err := checkKnownFields(&root, reflect.TypeOf(Config{})); err != nil {
+		return nil, fmt.Errorf("unknown config keys: %w", err)
+	}
+
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := root.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("unmarshal config: %w", err)
 	}
 
@@ -43,6 +60,71 @@ func applyDefaults(cfg *Config) {
 	}
 }
 
+// checkKnownFields walks the YAML tree alongside the Go type it decodes into and
+// reports every mapping key with no matching yaml tag, with its line and path.
+func checkKnownFields(root *yaml.Node, t reflect.Type) error {
+	var errs []error
+	walkKnownFields(root, t, "", &errs)
+	return errors.Join(errs...)
+}
+
+func walkKnownFields(n *yaml.Node, t reflect.Type, path string, errs *[]error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			walkKnownFields(c, t, path, errs)
+		}
+	case yaml.AliasNode:
+		walkKnownFields(n.Alias, t, path, errs)
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for i, c := range n.Content {
+			walkKnownFields(c, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Map:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walkKnownFields(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value), errs)
+			}
+		case reflect.Struct:
+			fields := make(map[string]reflect.Type)
+			for _, f := range yamlFields(t) {
+				fields[f.name] = f.Type
+			}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i]
+				if key.Value == "<<" { // YAML merge key
+					walkKnownFields(n.Content[i+1], t, path, errs)
+					continue
+				}
+				ft, ok := fields[key.Value]
+				if !ok {
+					where := path
+					if where == "" {
+						where = "top level"
+					}
+					*errs = append(*errs, fmt.Errorf("line %d: unknown key %q in %s", key.Line, key.Value, where))
+					continue
+				}
+				walkKnownFields(n.Content[i+1], ft, joinPath(path, key.Value), errs)
+			}
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
 func containsDot(s string) bool {
 	for _, c := range s {
 		if c == '.' {
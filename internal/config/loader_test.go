@@ -202,3 +202,29 @@ tasks:
 		t.Errorf("tracking.column mismatch: got %q want %q", got, want)
 	}
 }
+
+func TestLoadConfig_UnknownKeys_ReturnsError(t *testing.T) {
+	yaml := `
+postgres:
+  host: localhost
+  log_sql: true
+tasks:
+  - name: orders_stream
+    table: public.orders
+    fields: [id, created_at]
+    schedule: "@every 10s"
+    trackng:
+      column: created_at
+`
+	path := writeTempYAML(t, yaml)
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatalf("expected error for unknown keys, got nil")
+	}
+	for _, want := range []string{`line 4: unknown key "log_sql" in postgres`, `unknown key "trackng" in tasks[0]`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error, got: %v", want, err)
+		}
+	}
+}
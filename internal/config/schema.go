@@ -0,0 +1,286 @@
+// internal/config/schema.go
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"red-courier/internal/pgvalue"
+)
+
+// SchemaID is the draft used for the generated config schema.
+const SchemaID = "https://json-schema.org/draft/2020-12/schema"
+
+type jsonSchema = map[string]any
+
+// JSONSchema generates the JSON Schema for config.yaml from the Config types.
+// Properties come from the yaml struct tags; enums and per-structure required
+// fields come from the same tables Validate uses, so the two cannot drift apart.
+func JSONSchema() ([]byte, error) {
+	root := typeSchema(reflect.TypeOf(Config{}))
+	root["$schema"] = SchemaID
+	root["title"] = "Red Courier configuration"
+
+	props := root["properties"].(jsonSchema)
+	props["scheduler"].(jsonSchema)["properties"].(jsonSchema)["max_concurrent_tasks"] = jsonSchema{
+		"type": "integer", "minimum": 1, "maximum": PoolMaxConns, "default": DefaultMaxConcurrentTasks,
+	}
+	tl := props["scheduler"].(jsonSchema)["properties"].(jsonSchema)["task_locks"].(jsonSchema)["properties"].(jsonSchema)
+	tl["key_prefix"].(jsonSchema)["default"] = DefaultLockKeyPrefix
+	tl["ttl"].(jsonSchema)["default"] = DefaultLockTTL.String()
+	le := props["leader_election"].(jsonSchema)["properties"].(jsonSchema)
+	le["key"].(jsonSchema)["default"] = DefaultLeaderKey
+	le["ttl"].(jsonSchema)["default"] = DefaultLeaderTTL.String()
+	le["renew_interval"].(jsonSchema)["examples"] = []string{"5s"}
+	task := props["tasks"].(jsonSchema)["items"].(jsonSchema)
+	annotateTask(task)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func annotateTask(task jsonSchema) {
+	props := task["properties"].(jsonSchema)
+	task["required"] = []string{"name"}
+	task["oneOf"] = []jsonSchema{{"required": []string{"table"}}, {"required": []string{"query"}}}
+
+	props["structure"].(jsonSchema)["enum"] = structures
+	props["structure"].(jsonSchema)["default"] = "stream"
+	props["fields"].(jsonSchema)["minItems"] = 1
+	props["overlap"].(jsonSchema)["enum"] = Overlaps
+	props["overlap"].(jsonSchema)["default"] = OverlapSkip
+	props["timeout"].(jsonSchema)["default"] = DefaultTaskTimeout.String()
+	props["jitter"].(jsonSchema)["examples"] = []string{"10s"}
+	props["timezone"].(jsonSchema)["examples"] = []string{"America/New_York"}
+
+	retry := props["retry"].(jsonSchema)["properties"].(jsonSchema)
+	retry["max_attempts"].(jsonSchema)["minimum"] = 1
+	retry["max_attempts"].(jsonSchema)["default"] = DefaultRetryAttempts
+	retry["initial_backoff"].(jsonSchema)["default"] = DefaultRetryBackoff.String()
+	retry["max_backoff"].(jsonSchema)["default"] = DefaultRetryMaxBackoff.String()
+	breaker := props["circuit_breaker"].(jsonSchema)["properties"].(jsonSchema)
+	breaker["failure_threshold"].(jsonSchema)["minimum"] = 1
+	breaker["failure_threshold"].(jsonSchema)["default"] = DefaultFailureThreshold
+	breaker["pause"].(jsonSchema)["default"] = DefaultBreakerPause.String()
+
+	tracking := props["tracking"].(jsonSchema)
+	tracking["required"] = []string{"operator", "last_value_key"}
+	tracking["oneOf"] = []jsonSchema{{"required": []string{"column"}}, {"required": []string{"columns"}}}
+	tracking["properties"].(jsonSchema)["columns"].(jsonSchema)["minItems"] = 1
+	tracking["properties"].(jsonSchema)["columns"].(jsonSchema)["uniqueItems"] = true
+	tracking["properties"].(jsonSchema)["operator"].(jsonSchema)["enum"] = trackingOperators
+
+	trigger := props["trigger"].(jsonSchema)
+	trigger["required"] = []string{"listen"}
+	trigger["properties"].(jsonSchema)["listen"].(jsonSchema)["pattern"] = identPattern.String()
+	trigger["properties"].(jsonSchema)["debounce"].(jsonSchema)["default"] = DefaultTriggerDebounce.String()
+
+	props["encoding"].(jsonSchema)["if"] = jsonSchema{"required": []string{"null_value"}}
+	props["encoding"].(jsonSchema)["then"] = jsonSchema{
+		"properties": jsonSchema{"nulls": jsonSchema{"const": pgvalue.NullsValue}},
+		"required":   []string{"nulls"},
+	}
+	encoding := props["encoding"].(jsonSchema)["properties"].(jsonSchema)
+	encoding["time_format"].(jsonSchema)["default"] = "rfc3339nano"
+	encoding["time_format"].(jsonSchema)["examples"] = []string{"rfc3339nano", "rfc3339", "date", "datetime", pgvalue.FormatUnix, pgvalue.FormatUnixMilli, "2006-01-02 15:04:05"}
+	encoding["timezone"].(jsonSchema)["examples"] = []string{"UTC", "America/New_York"}
+	encoding["nulls"].(jsonSchema)["enum"] = pgvalue.NullModes
+	encoding["nulls"].(jsonSchema)["default"] = pgvalue.NullsEmpty
+	encoding["bool_format"].(jsonSchema)["enum"] = pgvalue.BoolFormats
+	encoding["bool_format"].(jsonSchema)["default"] = pgvalue.BoolNumeric
+
+	cdc := props["cdc"].(jsonSchema)
+	cdc["required"] = []string{"slot", "publication", "lsn_key"}
+	cdc["properties"].(jsonSchema)["slot"].(jsonSchema)["pattern"] = identPattern.String()
+	cdc["properties"].(jsonSchema)["publication"].(jsonSchema)["pattern"] = identPattern.String()
+
+	stream := props["stream"].(jsonSchema)
+	stream["not"] = jsonSchema{"required": []string{"max_len", "max_age"}}
+	stream["dependentRequired"] = jsonSchema{"id_column": []string{"id_sequence_column"}, "id_sequence_column": []string{"id_column"}}
+	streamProps := stream["properties"].(jsonSchema)
+	streamProps["max_len"] = jsonSchema{"type": "integer", "minimum": 1}
+	streamProps["max_age"].(jsonSchema)["examples"] = []string{"24h", "168h"}
+	dedup := streamProps["dedup"].(jsonSchema)
+	dedup["required"] = []string{"column", "key"}
+	dedup["properties"].(jsonSchema)["window"].(jsonSchema)["default"] = DefaultDedupWindow.String()
+
+	props["snapshot"].(jsonSchema)["properties"].(jsonSchema)["structure"].(jsonSchema)["enum"] = snapshotStructures
+	props["snapshot"].(jsonSchema)["properties"].(jsonSchema)["structure"].(jsonSchema)["default"] = "stream"
+
+	props["batch_size"] = jsonSchema{"type": "integer", "minimum": 1, "maximum": MaxBatchSize, "default": DefaultBatchSize}
+	props["page_size"] = jsonSchema{"type": "integer", "minimum": 1}
+	pagingRule := jsonSchema{
+		"if":   jsonSchema{"required": []string{"page_size"}},
+		"then": jsonSchema{"required": []string{"tracking"}},
+	}
+	props["mode"].(jsonSchema)["enum"] = modes
+	props["mode"].(jsonSchema)["default"] = "append"
+	props["max_delete_ratio"] = jsonSchema{"type": "number", "minimum": 0, "maximum": 1, "default": DefaultMaxDeleteRatio}
+
+	isSnapshot := jsonSchema{
+		"properties": jsonSchema{"structure": jsonSchema{"const": "snapshot"}},
+		"required":   []string{"structure"},
+	}
+	rules := []jsonSchema{pagingRule, {
+		"if":   jsonSchema{"required": []string{"stream"}},
+		"then": jsonSchema{"properties": jsonSchema{"structure": jsonSchema{"const": "stream"}}},
+	}, {
+		"if": isSnapshot,
+		"then": jsonSchema{
+			"not": jsonSchema{"anyOf": []jsonSchema{
+				{"required": []string{"tracking"}},
+				{"properties": jsonSchema{"overlap": jsonSchema{"const": OverlapAllow}}, "required": []string{"overlap"}},
+			}},
+		},
+		"else": jsonSchema{"not": jsonSchema{"required": []string{"snapshot"}}},
+	}}
+	rules = append(rules, jsonSchema{
+		"if": jsonSchema{
+			"properties": jsonSchema{"mode": jsonSchema{"const": "replace"}},
+			"required":   []string{"mode"},
+		},
+		"then": jsonSchema{
+			"properties": jsonSchema{"structure": jsonSchema{"enum": replaceStructures}},
+			"required":   []string{"structure"},
+			"not":        jsonSchema{"required": []string{"tracking"}},
+		},
+		"else": jsonSchema{"not": jsonSchema{"required": []string{"max_delete_ratio"}}},
+	})
+	rules = append(rules, jsonSchema{
+		"if": jsonSchema{"required": []string{"cdc"}},
+		"then": jsonSchema{
+			"required":   []string{"table"},
+			"properties": jsonSchema{"structure": jsonSchema{"enum": cdcStructures}},
+			"not": jsonSchema{"anyOf": []jsonSchema{
+				{"required": []string{"schedule"}}, {"required": []string{"query"}}, {"required": []string{"where"}},
+				{"required": []string{"tracking"}}, {"required": []string{"page_size"}}, {"required": []string{"trigger"}},
+				{"required": []string{"overlap"}}, {"required": []string{"retry"}}, {"required": []string{"circuit_breaker"}},
+				{"required": []string{"timeout"}}, {"required": []string{"jitter"}}, {"required": []string{"timezone"}},
+				{"properties": jsonSchema{"mode": jsonSchema{"const": "replace"}}, "required": []string{"mode"}},
+				{"properties": jsonSchema{"stream": jsonSchema{"anyOf": []jsonSchema{
+					{"required": []string{"id_column"}}, {"required": []string{"dedup"}},
+				}}}, "required": []string{"stream"}},
+			}},
+		},
+		"else": jsonSchema{"required": []string{"schedule"}},
+	})
+	isRow := jsonSchema{
+		"properties": jsonSchema{"structure": jsonSchema{"const": "row"}},
+		"required":   []string{"structure"},
+	}
+	rules = append(rules, jsonSchema{
+		"if":   jsonSchema{"required": []string{"query"}},
+		"then": jsonSchema{"not": jsonSchema{"anyOf": []jsonSchema{{"required": []string{"where"}}, {"required": []string{"page_size"}}}}},
+	}, jsonSchema{
+		"if":   jsonSchema{"required": []string{"query"}, "not": isRow},
+		"then": jsonSchema{"required": []string{"alias"}},
+	})
+	rules = append(rules, jsonSchema{
+		"if": isRow,
+		"then": jsonSchema{
+			"anyOf": []jsonSchema{{"required": []string{"key_template"}}, {"required": []string{"key"}}},
+			"oneOf": []jsonSchema{{"required": []string{"value"}}, {"required": []string{"fields"}}},
+		},
+		"else": jsonSchema{"not": jsonSchema{"required": []string{"key_template"}}},
+	})
+	for _, r := range structureRequirements {
+		// An omitted structure (or snapshot.structure) defaults to "stream", so only
+		// require the key to be present when the rule does not cover the default.
+		defaulted := contains(r.structures, "stream")
+
+		direct := jsonSchema{
+			"properties": jsonSchema{"structure": jsonSchema{"enum": r.structures}},
+		}
+		inner := jsonSchema{
+			"properties": jsonSchema{"structure": jsonSchema{"enum": r.structures}},
+		}
+		snapshot := jsonSchema{
+			"properties": jsonSchema{
+				"structure": jsonSchema{"const": "snapshot"},
+				"snapshot":  inner,
+			},
+			"required": []string{"structure"},
+		}
+		if !defaulted {
+			direct["required"] = []string{"structure"}
+			inner["required"] = []string{"structure"}
+			snapshot["required"] = []string{"structure", "snapshot"}
+		}
+		rules = append(rules, jsonSchema{
+			"if":   jsonSchema{"anyOf": []jsonSchema{direct, snapshot}},
+			"then": jsonSchema{"required": r.fields},
+		})
+	}
+	task["allOf"] = rules
+}
+
+// typeSchema maps a Go type onto its JSON Schema representation.
+func typeSchema(t reflect.Type) jsonSchema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Bool:
+		return orReference("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return orReference("integer")
+	case reflect.Float32, reflect.Float64:
+		return orReference("number")
+	case reflect.Slice, reflect.Array:
+		return jsonSchema{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return jsonSchema{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		props := jsonSchema{}
+		for _, f := range yamlFields(t) {
+			props[f.name] = typeSchema(f.Type)
+		}
+		return jsonSchema{"type": "object", "properties": props, "additionalProperties": false}
+	default:
+		return jsonSchema{}
+	}
+}
+
+// orReference accepts a non-string scalar either literally or as a ${...}
+// reference, which is only expanded after the YAML has been parsed.
+func orReference(typ string) jsonSchema {
+	return jsonSchema{"anyOf": []jsonSchema{
+		{"type": typ},
+		{"type": "string", "pattern": `\$\{[^}]+\}`},
+	}}
+}
+
+type yamlField struct {
+	reflect.StructField
+	name string
+}
+
+// yamlFields lists the exported fields of a struct under their YAML key names.
+func yamlFields(t reflect.Type) []yamlField {
+	var out []yamlField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		out = append(out, yamlField{StructField: f, name: name})
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+// internal/config/schema_test.go
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestJSONSchema_MatchesCommittedFile(t *testing.T) {
+	got, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema error: %v", err)
+	}
+	want, err := os.ReadFile("../../config.schema.json")
+	if err != nil {
+		t.Fatalf("read committed schema: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("config.schema.json is out of date; run `make schema`")
+	}
+}
+
+func TestJSONSchema_TaskEnums(t *testing.T) {
+	b, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema error: %v", err)
+	}
+	var root map[string]any
+	if err := json.Unmarshal(b, &root); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	task := root["properties"].(map[string]any)["tasks"].(map[string]any)["items"].(map[string]any)
+	props := task["properties"].(map[string]any)
+
+	if got := len(props["structure"].(map[string]any)["enum"].([]any)); got != len(structures) {
+		t.Errorf("structure enum: got %d values want %d", got, len(structures))
+	}
+	op := props["tracking"].(map[string]any)["properties"].(map[string]any)["operator"].(map[string]any)
+	if got := len(op["enum"].([]any)); got != len(trackingOperators) {
+		t.Errorf("operator enum: got %d values want %d", got, len(trackingOperators))
+	}
+	if task["additionalProperties"] != false {
+		t.Errorf("expected tasks to reject unknown properties")
+	}
+}
@@ -1,11 +1,39 @@
 package config
 
 type Config struct {
-	Postgres PostgresConfig `yaml:"postgres"`
-	Redis    RedisConfig    `yaml:"redis"`
-	Tasks    []TaskConfig   `yaml:"tasks"`
-	Server   ServerConfig   `yaml:"server_port"`
-	LogSQL   bool           `yaml:"log_sql"`
+	Postgres       PostgresConfig        `yaml:"postgres"`
+	Redis          RedisConfig           `yaml:"redis"`
+	Tasks          []TaskConfig          `yaml:"tasks"`
+	Server         ServerConfig          `yaml:"server"`
+	Scheduler      SchedulerConfig       `yaml:"scheduler"`
+	LeaderElection *LeaderElectionConfig `yaml:"leader_election,omitempty"`
+	LogSQL         bool                  `yaml:"log_sql"`
+}
+
+// LeaderElectionConfig lets several replicas share one config: they compete for a
+// lease in Redis and only the replica holding it runs tasks.
+type LeaderElectionConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Key           string `yaml:"key,omitempty"`            // Redis key holding the lease; default "red-courier:leader"
+	TTL           string `yaml:"ttl,omitempty"`            // how long a lease lasts without renewal; default "15s"
+	RenewInterval string `yaml:"renew_interval,omitempty"` // how often the leader renews and followers retry; default ttl/3
+	ID            string `yaml:"id,omitempty"`             // this replica's name in the lease; default hostname and pid
+}
+
+// SchedulerConfig holds settings shared by all scheduled tasks.
+type SchedulerConfig struct {
+	MaxConcurrentTasks int              `yaml:"max_concurrent_tasks,omitempty"` // runs in flight at once across all tasks; default DefaultMaxConcurrentTasks
+	TaskLocks          *TaskLocksConfig `yaml:"task_locks,omitempty"`
+}
+
+// TaskLocksConfig lets several replicas share the tasks: every replica schedules
+// every task, and a run first takes the task's lock in Redis, so each task runs
+// on one replica at a time.
+type TaskLocksConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	KeyPrefix string `yaml:"key_prefix,omitempty"` // prepended to the task name; default "red-courier:lock:"
+	TTL       string `yaml:"ttl,omitempty"`        // how long a lock outlives a replica that died mid-run; default "30s"
+	ID        string `yaml:"id,omitempty"`         // this replica's name in the locks; default hostname and pid
 }
 
 type ServerConfig struct {
@@ -28,26 +56,116 @@ type RedisConfig struct {
 }
 
 type TaskConfig struct {
-	Name      string            `yaml:"name"`
-	Table     string            `yaml:"table"`
-	Alias     string            `yaml:"alias,omitempty"`
-	Where     string            `yaml:"where,omitempty"`
-	Structure string            `yaml:"structure"`
-	Key       string            `yaml:"key,omitempty"`
-	Value     string            `yaml:"value,omitempty"`
-	Score     string            `yaml:"score,omitempty"`
-	Fields    []string          `yaml:"fields,omitempty"`
-	KeyPrefix string            `yaml:"key_prefix,omitempty"`
-	Schedule  string            `yaml:"schedule"`
-	ColumnMap map[string]string `yaml:"column_map,omitempty"`
-	Tracking  *TrackingConfig   `yaml:"tracking,omitempty"`
-	LogSQL    *bool             `yaml:"log_sql"`
+	Name           string                `yaml:"name"`
+	Table          string                `yaml:"table"`
+	Alias          string                `yaml:"alias,omitempty"`
+	Where          string                `yaml:"where,omitempty"`
+	Query          string                `yaml:"query,omitempty"` // full SELECT used instead of table/where; ":last_value" binds the tracking cursor
+	Structure      string                `yaml:"structure"`
+	Key            string                `yaml:"key,omitempty"`
+	Value          string                `yaml:"value,omitempty"`
+	Score          string                `yaml:"score,omitempty"`
+	Fields         []string              `yaml:"fields,omitempty"`
+	KeyPrefix      string                `yaml:"key_prefix,omitempty"`
+	KeyTemplate    string                `yaml:"key_template,omitempty"` // per-row key for structure "row", e.g. "order:{id}"
+	Schedule       string                `yaml:"schedule"`
+	Overlap        string                `yaml:"overlap,omitempty"`  // "skip" (default), "queue" or "allow" a run while the previous one is in flight
+	Timeout        string                `yaml:"timeout,omitempty"`  // how long one run may take; default "1m"
+	Jitter         string                `yaml:"jitter,omitempty"`   // random delay of up to this before a scheduled run starts
+	Timezone       string                `yaml:"timezone,omitempty"` // IANA zone the cron fields are read in; default the process's local time
+	Trigger        *TriggerConfig        `yaml:"trigger,omitempty"`
+	Retry          *RetryConfig          `yaml:"retry,omitempty"`
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuit_breaker,omitempty"`
+	CDC            *CDCConfig            `yaml:"cdc,omitempty"`
+	ColumnMap      map[string]string     `yaml:"column_map,omitempty"`
+	Encoding       *EncodingConfig       `yaml:"encoding,omitempty"`
+	Tracking       *TrackingConfig       `yaml:"tracking,omitempty"`
+	Snapshot       *SnapshotConfig       `yaml:"snapshot,omitempty"`
+	Stream         *StreamConfig         `yaml:"stream,omitempty"`
+	Mode           string                `yaml:"mode,omitempty"`             // "append" (default) or "replace"
+	MaxDeleteRatio *float64              `yaml:"max_delete_ratio,omitempty"` // share of existing members a "replace" run may delete
+	BatchSize      int                   `yaml:"batch_size,omitempty"`       // rows per pipelined Redis round trip
+	PageSize       int                   `yaml:"page_size,omitempty"`        // rows per keyset page; 0 fetches everything at once
+	LogSQL         *bool                 `yaml:"log_sql"`
+}
+
+// SnapshotConfig configures structure "snapshot": every run loads the full result
+// set into a temporary key and atomically renames it over the live key.
+type SnapshotConfig struct {
+	Structure string `yaml:"structure"` // structure of the snapshot key; defaults to "stream"
+}
+
+// StreamConfig tunes the XADD of structure "stream": approximate trimming, entry
+// IDs taken from the row so replays are rejected, and de-duplication by key.
+type StreamConfig struct {
+	MaxLen           int64        `yaml:"max_len,omitempty"`            // trim to about this many entries (MAXLEN ~)
+	MaxAge           string       `yaml:"max_age,omitempty"`            // trim entries with IDs older than this (MINID ~), e.g. "168h"
+	IDColumn         string       `yaml:"id_column,omitempty"`          // timestamp or integer column giving the entry ID's milliseconds
+	IDSequenceColumn string       `yaml:"id_sequence_column,omitempty"` // integer column giving the entry ID's sequence number
+	Dedup            *DedupConfig `yaml:"dedup,omitempty"`
+}
+
+// DedupConfig skips rows whose key was already published within a window.
+type DedupConfig struct {
+	Column string `yaml:"column"`           // column identifying a row, usually the primary key
+	Key    string `yaml:"key"`              // Redis sorted set of recently published keys
+	Window string `yaml:"window,omitempty"` // how long a key is remembered; default "24h"
+}
+
+// RetryConfig retries a run that failed with a transient error (a lost
+// connection, a timeout) instead of waiting for the next scheduled run.
+type RetryConfig struct {
+	MaxAttempts    int    `yaml:"max_attempts,omitempty"`    // attempts per run, including the first; default 3
+	InitialBackoff string `yaml:"initial_backoff,omitempty"` // wait before the first retry, doubled for each further one; default "1s"
+	MaxBackoff     string `yaml:"max_backoff,omitempty"`     // upper bound on the wait; default "30s"
+}
+
+// CircuitBreakerConfig pauses a task whose runs keep failing.
+type CircuitBreakerConfig struct {
+	FailureThreshold int    `yaml:"failure_threshold,omitempty"` // consecutive failed runs that pause the task; default 5
+	Pause            string `yaml:"pause,omitempty"`             // how long the task is paused before it is tried again; default "10m"
+}
+
+// TriggerConfig runs a task when Postgres sends a NOTIFY on a channel, in addition
+// to its cron schedule.
+type TriggerConfig struct {
+	Listen   string `yaml:"listen"`             // channel to LISTEN on
+	Debounce string `yaml:"debounce,omitempty"` // run once notifications pause this long; default "1s"
+}
+
+// EncodingConfig controls how column values are written to Redis.
+type EncodingConfig struct {
+	TimeFormat string `yaml:"time_format,omitempty"` // rfc3339nano (default), rfc3339, date, datetime, unix, unix_ms or a Go layout
+	Timezone   string `yaml:"timezone,omitempty"`    // IANA zone timestamps are converted to, e.g. "UTC"
+	Nulls      string `yaml:"nulls,omitempty"`       // "empty" (default), "skip" or "value"
+	NullValue  string `yaml:"null_value,omitempty"`  // written for NULL with nulls "value"
+	BoolFormat string `yaml:"bool_format,omitempty"` // "numeric" (default, "1"/"0") or "text" ("true"/"false")
+}
+
+// CDCConfig streams a table's changes from a logical replication slot (pgoutput)
+// instead of polling it on a schedule.
+type CDCConfig struct {
+	Slot        string `yaml:"slot"`        // replication slot; created when missing
+	Publication string `yaml:"publication"` // publication that includes the task's table
+	LSNKey      string `yaml:"lsn_key"`     // Redis key holding the last applied commit LSN
 }
 
 type TrackingConfig struct {
-	Column       string `yaml:"column"`
-	Operator     string `yaml:"operator"`       // ">" or "<"
-	LastValueKey string `yaml:"last_value_key"` // Redis key to store last seen value
+	Column       string   `yaml:"column,omitempty"`
+	Columns      []string `yaml:"columns,omitempty"` // composite cursor, e.g. [updated_at, id]; replaces column
+	Operator     string   `yaml:"operator"`          // ">" or "<"
+	LastValueKey string   `yaml:"last_value_key"`    // Redis key to store last seen value
+}
+
+// CursorColumns returns the logical tracking columns: Columns, or Column on its own.
+func (t TrackingConfig) CursorColumns() []string {
+	if len(t.Columns) > 0 {
+		return t.Columns
+	}
+	if t.Column == "" {
+		return nil
+	}
+	return []string{t.Column}
 }
 
 func (t *TaskConfig) EffectiveRedisKey() string {
@@ -57,6 +175,19 @@ func (t *TaskConfig) EffectiveRedisKey() string {
 	return t.Table
 }
 
+// TrackingColumns returns the resolved database columns of the tracking cursor.
+func (t *TaskConfig) TrackingColumns() []string {
+	if t.Tracking == nil {
+		return nil
+	}
+	logical := t.Tracking.CursorColumns()
+	cols := make([]string, len(logical))
+	for i, c := range logical {
+		cols[i] = t.ResolveColumn(c)
+	}
+	return cols
+}
+
 func (t *TaskConfig) ResolveColumn(logicalName string) string {
 	if actual, ok := t.ColumnMap[logicalName]; ok {
 		return actual
@@ -2,39 +2,507 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/robfig/cron/v3"
+	"red-courier/internal/pgvalue"
 )
 
 var validate = validator.New()
 
+// Supported values for TaskConfig.Structure.
+var structures = []string{"map", "list", "set", "sorted_set", "stream", "snapshot", "row"}
+
+// Supported values for SnapshotConfig.Structure.
+var snapshotStructures = []string{"map", "list", "set", "sorted_set", "stream"}
+
+// Fields each structure requires, shared by Validate and JSONSchema.
+// Snapshot tasks must satisfy the requirements of their snapshot structure.
+var structureRequirements = []struct {
+	structures []string
+	fields     []string
+}{
+	{[]string{"map"}, []string{"key", "value"}},
+	{[]string{"sorted_set"}, []string{"value", "score"}},
+	{[]string{"list", "set"}, []string{"value"}},
+	{[]string{"stream"}, []string{"fields"}},
+}
+
+// Supported values for TaskConfig.Mode, and the structures "replace" works with.
+var modes = []string{"append", "replace"}
+var replaceStructures = []string{"map", "set", "sorted_set"}
+
+// identPattern restricts trigger.listen, cdc.slot and cdc.publication to plain
+// lower-case identifiers, so they read the same quoted or not in any SQL they end up in.
+var identPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// Structures a CDC task can apply row-level changes to.
+var cdcStructures = []string{"map", "set", "sorted_set", "stream", "row"}
+
+// Supported values for TrackingConfig.Operator.
+var trackingOperators = []string{">", ">=", "<", "<="}
+
+// Validate checks the whole config and returns every problem found, joined with errors.Join,
+// so an operator can fix the file in one pass. It returns nil when the config is valid.
 func Validate(cfg *Config) error {
+	var errs []error
+
 	if err := validate.Struct(cfg); err != nil {
-		return err
+		var verrs validator.ValidationErrors
+		if errors.As(err, &verrs) {
+			for _, fe := range verrs {
+				errs = append(errs, fmt.Errorf("%s: failed %q validation", fe.Namespace(), fe.Tag()))
+			}
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	if n := cfg.Scheduler.MaxConcurrentTasks; n < 0 || n > PoolMaxConns {
+		errs = append(errs, fmt.Errorf("scheduler.max_concurrent_tasks %d must be between 1 and %d (the Postgres pool size)", n, PoolMaxConns))
+	}
+
+	if le := cfg.LeaderElection; le != nil {
+		errs = append(errs, validateLeaderElection(*le)...)
+	}
+	if tl := cfg.Scheduler.TaskLocks; tl != nil {
+		if d, err := time.ParseDuration(tl.TTL); tl.TTL != "" && (err != nil || d < time.Second) {
+			errs = append(errs, fmt.Errorf("scheduler.task_locks.ttl %q must be a duration of at least 1s", tl.TTL))
+		}
+		if tl.Enabled && cfg.LeaderElection != nil && cfg.LeaderElection.Enabled {
+			errs = append(errs, errors.New("scheduler.task_locks and leader_election cannot both be enabled"))
+		}
+	}
+
+	seen := make(map[string]int)
+	for i, t := range cfg.Tasks {
+		if t.Name != "" {
+			if first, dup := seen[t.Name]; dup {
+				errs = append(errs, fmt.Errorf("task %q: duplicate name (tasks[%d] and tasks[%d])", t.Name, first, i))
+			} else {
+				seen[t.Name] = i
+			}
+		}
+		errs = append(errs, validateTask(i, t)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTask returns every problem with a single task.
+func validateTask(idx int, t TaskConfig) []error {
+	label := t.Name
+	if label == "" {
+		label = fmt.Sprintf("tasks[%d]", idx)
+	}
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("task %q: %s", label, fmt.Sprintf(format, args...)))
+	}
+
+	if t.Name == "" {
+		fail("name is required")
+	}
+	// table must be "schema.table" or bare "table"; a custom query replaces it
+	switch {
+	case t.Query != "":
+		if t.Table != "" {
+			fail("table and query are mutually exclusive")
+		}
+		if t.Where != "" {
+			fail("where cannot be combined with query (put the filter in the query)")
+		}
+		if t.PageSize > 0 {
+			fail("page_size is not supported with query")
+		}
+		if t.Alias == "" && t.Structure != "row" {
+			fail("alias is required with query (it names the Redis key)")
+		}
+	case t.Table == "":
+		fail("table (or query) is required")
+	case strings.Count(t.Table, ".") > 1:
+		fail("invalid table %q", t.Table)
+	}
+	// schedule: allow robfig cron or "@every"; CDC tasks stream instead
+	if t.CDC == nil {
+		validateTiming(t, fail)
+	} else {
+		if t.Schedule != "" {
+			fail("schedule is not used with cdc (changes are streamed as they commit)")
+		}
+		if t.Timeout != "" || t.Jitter != "" || t.Timezone != "" {
+			fail("timeout, jitter and timezone are not used with cdc (changes are streamed as they commit)")
+		}
 	}
-	// Per-task custom rules
-	for _, t := range cfg.Tasks {
-		// schedule: allow robfig cron or "@every"
-		if err := validateSchedule(t.Schedule); err != nil {
-			return fmt.Errorf("task %q: %w", t.Name, err)
+
+	if t.Overlap != "" {
+		if !contains(Overlaps, t.Overlap) {
+			fail("unknown overlap %q (must be one of %s)", t.Overlap, strings.Join(Overlaps, ", "))
+		}
+		if t.CDC != nil {
+			fail("overlap is not used with cdc (changes are streamed by a single consumer)")
+		}
+	}
+
+	if t.Retry != nil || t.CircuitBreaker != nil {
+		validateFailurePolicy(t, fail)
+	}
+
+	if !contains(structures, t.Structure) {
+		fail("unknown structure %q (must be one of %s)", t.Structure, strings.Join(structures, ", "))
+	}
+	if t.Trigger != nil {
+		if !identPattern.MatchString(t.Trigger.Listen) {
+			fail("trigger.listen %q must be a lower-case identifier (letters, digits, underscores; at most 63 characters)", t.Trigger.Listen)
+		}
+		if t.Trigger.Debounce != "" {
+			if d, err := time.ParseDuration(t.Trigger.Debounce); err != nil || d < 0 {
+				fail("trigger.debounce %q is not a valid duration", t.Trigger.Debounce)
+			}
+		}
+	}
+
+	if t.CDC != nil {
+		validateCDC(t, fail)
+	}
+	if t.Encoding != nil {
+		if _, err := t.Encoder(); err != nil {
+			fail("encoding: %v", err)
+		}
+		if t.Encoding.NullValue != "" && t.Encoding.Nulls != pgvalue.NullsValue {
+			fail("encoding.null_value requires nulls \"value\"")
+		}
+	}
+
+	if t.Structure == "snapshot" {
+		if !contains(snapshotStructures, t.DataStructure()) {
+			fail("unknown snapshot.structure %q (must be one of %s)", t.DataStructure(), strings.Join(snapshotStructures, ", "))
 		}
-		// tracking column must be among resolved fields (after alias resolution if any)
 		if t.Tracking != nil {
-			if !contains(t.Fields, t.Tracking.Column) {
-				return fmt.Errorf("task %q: tracking.column %q not in fields", t.Name, t.Tracking.Column)
+			fail("tracking is not supported for structure \"snapshot\" (every run replaces the whole key)")
+		}
+		if t.Overlap == OverlapAllow {
+			fail("overlap allow is not supported for structure \"snapshot\" (concurrent runs would build into the same temporary key)")
+		}
+	} else if t.Snapshot != nil {
+		fail("snapshot is only valid with structure \"snapshot\"")
+	}
+	if t.Stream != nil {
+		if t.Structure != "stream" {
+			fail("stream is only valid with structure \"stream\"")
+		}
+		validateStream(t, fail)
+	}
+	if t.Structure == "row" {
+		if tmpl := t.EffectiveKeyTemplate(); tmpl == "" {
+			fail("key_template (or key with optional key_prefix) is required for structure \"row\"")
+		} else if _, err := ParseKeyTemplate(tmpl); err != nil {
+			fail("%v", err)
+		}
+		if (t.Value == "") == (len(t.Fields) == 0) {
+			fail("structure \"row\" needs exactly one of value (string per row) or fields (hash per row)")
+		}
+	} else if t.KeyTemplate != "" {
+		fail("key_template is only valid with structure \"row\"")
+	}
+
+	if t.BatchSize < 0 || t.BatchSize > MaxBatchSize {
+		fail("batch_size %d must be between 1 and %d", t.BatchSize, MaxBatchSize)
+	}
+
+	if t.PageSize < 0 {
+		fail("page_size %d must be positive", t.PageSize)
+	} else if t.PageSize > 0 && t.Tracking == nil {
+		fail("page_size requires tracking (pages are keyed on tracking.column)")
+	}
+
+	if t.Mode != "" && !contains(modes, t.Mode) {
+		fail("unknown mode %q (must be one of %s)", t.Mode, strings.Join(modes, ", "))
+	}
+	if t.Mode == "replace" {
+		if !contains(replaceStructures, t.Structure) {
+			fail("mode \"replace\" is only supported for structures %s", strings.Join(replaceStructures, ", "))
+		}
+		if t.Tracking != nil {
+			fail("mode \"replace\" cannot be combined with tracking (incremental runs only see changed rows)")
+		}
+	}
+	if t.MaxDeleteRatio != nil {
+		if t.Mode != "replace" {
+			fail("max_delete_ratio is only valid with mode \"replace\"")
+		}
+		if r := *t.MaxDeleteRatio; r < 0 || r > 1 {
+			fail("max_delete_ratio %v must be between 0 and 1", r)
+		}
+	}
+
+	for _, r := range structureRequirements {
+		if !contains(r.structures, t.DataStructure()) {
+			continue
+		}
+		for _, f := range r.fields {
+			switch {
+			case f == "fields" && len(t.Fields) == 0:
+				fail("fields must not be empty for structure %q", t.DataStructure())
+			case f != "fields" && t.column(f) == "":
+				fail("%s is required for structure %q", f, t.DataStructure())
 			}
 		}
-		// table must be "schema.table" or bare "table"
-		if strings.Count(t.Table, ".") > 1 {
-			return fmt.Errorf("task %q: invalid table %q", t.Name, t.Table)
+	}
+
+	if t.Tracking != nil {
+		switch {
+		case t.Tracking.Column == "" && len(t.Tracking.Columns) == 0:
+			fail("tracking.column (or tracking.columns) is required")
+		case t.Tracking.Column != "" && len(t.Tracking.Columns) > 0:
+			fail("tracking.column and tracking.columns are mutually exclusive")
+		case t.DataStructure() == "stream":
+			// tracking columns must be among the configured fields
+			for _, c := range t.Tracking.CursorColumns() {
+				if !contains(t.Fields, c) {
+					fail("tracking column %q not in fields", c)
+				}
+			}
+		}
+		for i, c := range t.Tracking.Columns {
+			if c == "" || contains(t.Tracking.Columns[:i], c) {
+				fail("tracking.columns must be distinct and non-empty")
+				break
+			}
+		}
+		if !contains(trackingOperators, t.Tracking.Operator) {
+			fail("tracking.operator %q not supported (must be one of %s)", t.Tracking.Operator, strings.Join(trackingOperators, ", "))
+		}
+		if t.Tracking.LastValueKey == "" {
+			fail("tracking.last_value_key is required")
 		}
 	}
-	return nil
+
+	return errs
+}
+
+// validateCDC reports the settings a CDC task cannot use: changes arrive one row
+// at a time from the replication stream, so there is no query, filter or cursor.
+func validateCDC(t TaskConfig, fail func(format string, args ...any)) {
+	c := t.CDC
+	if !identPattern.MatchString(c.Slot) {
+		fail("cdc.slot %q must be a lower-case identifier (letters, digits, underscores; at most 63 characters)", c.Slot)
+	}
+	if !identPattern.MatchString(c.Publication) {
+		fail("cdc.publication %q must be a lower-case identifier (letters, digits, underscores; at most 63 characters)", c.Publication)
+	}
+	if c.LSNKey == "" {
+		fail("cdc.lsn_key is required")
+	}
+	if !contains(cdcStructures, t.Structure) {
+		fail("cdc is only supported for structures %s", strings.Join(cdcStructures, ", "))
+	}
+	for _, bad := range []struct {
+		set  bool
+		name string
+	}{
+		{t.Query != "", "query"},
+		{t.Where != "", "where"},
+		{t.Tracking != nil, "tracking"},
+		{t.PageSize > 0, "page_size"},
+		{t.Trigger != nil, "trigger"},
+		{t.Mode == "replace", `mode "replace"`},
+	} {
+		if bad.set {
+			fail("cdc cannot be combined with %s", bad.name)
+		}
+	}
+}
+
+// validateStream checks the stream settings. Redis trims by length or by ID, not
+// both, and a CDC task writes one entry per change, so its entries have no row to
+// take an ID from and are never replays of each other.
+func validateStream(t TaskConfig, fail func(format string, args ...any)) {
+	s := t.Stream
+	if s.MaxLen < 0 {
+		fail("stream.max_len %d must be positive", s.MaxLen)
+	}
+	if s.MaxAge != "" {
+		if d, err := time.ParseDuration(s.MaxAge); err != nil || d <= 0 {
+			fail("stream.max_age %q is not a valid duration", s.MaxAge)
+		}
+		if s.MaxLen > 0 {
+			fail("stream.max_len and stream.max_age are mutually exclusive")
+		}
+	}
+	// Both halves of the ID come from the row, so a re-sent row gets the ID it was
+	// first written with and Redis rejects it; with "<ms>-*" it would be a new entry.
+	if s.IDSequenceColumn != "" && s.IDColumn == "" {
+		fail("stream.id_sequence_column requires stream.id_column")
+	}
+	if s.IDColumn != "" && s.IDSequenceColumn == "" {
+		fail("stream.id_column requires stream.id_sequence_column")
+	}
+	// Redis rejects an entry whose ID is not above the stream's last one, so rows
+	// must arrive in ID order: the tracking cursor has to be the entry ID.
+	if cols := t.TrackingColumns(); s.IDColumn != "" && len(cols) > 0 {
+		if cols[0] != t.ResolveColumn(s.IDColumn) {
+			fail("stream.id_column %q must be the first tracking column", s.IDColumn)
+		}
+		if s.IDSequenceColumn != "" && (len(cols) < 2 || cols[1] != t.ResolveColumn(s.IDSequenceColumn)) {
+			fail("stream.id_sequence_column %q must be the second tracking column (tracking.columns: [%s, %s])", s.IDSequenceColumn, s.IDColumn, s.IDSequenceColumn)
+		}
+	}
+	if d := s.Dedup; d != nil {
+		if d.Column == "" {
+			fail("stream.dedup.column is required")
+		}
+		if d.Key == "" {
+			fail("stream.dedup.key is required")
+		}
+		if d.Window != "" {
+			if w, err := time.ParseDuration(d.Window); err != nil || w <= 0 {
+				fail("stream.dedup.window %q is not a valid duration", d.Window)
+			}
+		}
+	}
+	if t.CDC != nil {
+		if s.IDColumn != "" {
+			fail("stream.id_column cannot be combined with cdc (every change is a new entry)")
+		}
+		if s.Dedup != nil {
+			fail("stream.dedup cannot be combined with cdc (every change is a new entry)")
+		}
+	}
+}
+
+// validateFailurePolicy checks retry and circuit_breaker. CDC tasks reconnect on
+// their own and never finish a run, so neither applies to them.
+func validateFailurePolicy(t TaskConfig, fail func(format string, args ...any)) {
+	positive := func(field, v string) bool {
+		if v == "" {
+			return true
+		}
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			fail("%s %q is not a valid duration", field, v)
+			return false
+		}
+		return true
+	}
+	if r := t.Retry; r != nil {
+		if t.CDC != nil {
+			fail("retry is not used with cdc (the replication connection reconnects on its own)")
+		}
+		if r.MaxAttempts < 0 {
+			fail("retry.max_attempts %d must be at least 1", r.MaxAttempts)
+		}
+		initialOK := positive("retry.initial_backoff", r.InitialBackoff)
+		maxOK := positive("retry.max_backoff", r.MaxBackoff)
+		if initialOK && maxOK && r.EffectiveInitialBackoff() > r.EffectiveMaxBackoff() {
+			fail("retry.initial_backoff %s must not exceed max_backoff %s", r.EffectiveInitialBackoff(), r.EffectiveMaxBackoff())
+		}
+	}
+	if c := t.CircuitBreaker; c != nil {
+		if t.CDC != nil {
+			fail("circuit_breaker is not used with cdc (the replication connection reconnects on its own)")
+		}
+		if c.FailureThreshold < 0 {
+			fail("circuit_breaker.failure_threshold %d must be at least 1", c.FailureThreshold)
+		}
+		positive("circuit_breaker.pause", c.Pause)
+	}
+}
+
+// validateLeaderElection checks the lease timings: a renewal must land well before
+// the lease expires, or the leader would lose it between renewals.
+func validateLeaderElection(le LeaderElectionConfig) []error {
+	var errs []error
+	ttlOK, renewOK := true, true
+	if le.TTL != "" {
+		if d, err := time.ParseDuration(le.TTL); err != nil || d < time.Second {
+			errs = append(errs, fmt.Errorf("leader_election.ttl %q must be a duration of at least 1s", le.TTL))
+			ttlOK = false
+		}
+	}
+	if le.RenewInterval != "" {
+		if d, err := time.ParseDuration(le.RenewInterval); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("leader_election.renew_interval %q is not a valid duration", le.RenewInterval))
+			renewOK = false
+		}
+	}
+	if ttlOK && renewOK && le.EffectiveRenewInterval() > le.EffectiveTTL()/2 {
+		errs = append(errs, fmt.Errorf("leader_election.renew_interval %s must be at most half of ttl %s", le.EffectiveRenewInterval(), le.EffectiveTTL()))
+	}
+	return errs
 }
 
+// validateTiming checks the schedule with its timezone, and the run's timeout and
+// start jitter.
+func validateTiming(t TaskConfig, fail func(format string, args ...any)) {
+	every := strings.HasPrefix(t.Schedule, "@every ")
+	spec := t.CronSpec()
+	if t.Timezone != "" {
+		switch {
+		case every:
+			fail("timezone has no effect on @every schedules")
+		case strings.HasPrefix(t.Schedule, "CRON_TZ=") || strings.HasPrefix(t.Schedule, "TZ="):
+			fail("timezone cannot be combined with a CRON_TZ= prefix in schedule")
+		}
+		if _, err := time.LoadLocation(t.Timezone); err != nil {
+			fail("unknown timezone %q", t.Timezone)
+			spec = t.Schedule
+		}
+	}
+	if err := validateSchedule(spec); err != nil {
+		fail("%v", err)
+	}
+
+	if t.Timeout != "" {
+		if d, err := time.ParseDuration(t.Timeout); err != nil || d <= 0 {
+			fail("timeout %q is not a valid duration", t.Timeout)
+		}
+	}
+	if t.Jitter != "" {
+		d, err := time.ParseDuration(t.Jitter)
+		if err != nil || d < 0 {
+			fail("jitter %q is not a valid duration", t.Jitter)
+		} else if interval, ok := minInterval(spec); ok && d >= interval {
+			// A delay as long as the interval would push runs into the next one.
+			fail("jitter %s must be shorter than the schedule interval %s", d, interval)
+		}
+	}
+}
+
+// minInterval returns the shortest gap between two successive runs of a schedule
+// over its next intervalSamples runs, which spans the days and weeks of a cron
+// spec such as "0 9,10 * * 1-5" whose runs are not evenly spaced.
+func minInterval(spec string) (time.Duration, bool) {
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimPrefix(spec, "@every "))
+		return interval, err == nil
+	}
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return 0, false
+	}
+	var shortest time.Duration
+	prev := sched.Next(time.Now())
+	for i := 0; i < intervalSamples && !prev.IsZero(); i++ {
+		next := sched.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if gap := next.Sub(prev); shortest == 0 || gap < shortest {
+			shortest = gap
+		}
+		prev = next
+	}
+	return shortest, shortest > 0
+}
+
+// intervalSamples is how many gaps between runs minInterval compares.
+const intervalSamples = 200
+
 func validateSchedule(s string) error {
 	if strings.HasPrefix(s, "@every ") {
 		return nil
@@ -54,3 +522,16 @@ func contains(ss []string, want string) bool {
 	}
 	return false
 }
+
+// column returns the task's key, value or score setting by its YAML name.
+func (t TaskConfig) column(name string) string {
+	switch name {
+	case "key":
+		return t.Key
+	case "value":
+		return t.Value
+	case "score":
+		return t.Score
+	}
+	return ""
+}
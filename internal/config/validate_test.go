@@ -0,0 +1,541 @@
+// internal/config/validate_test.go
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	path := writeTempYAML(t, `
+tasks:
+  - name: prices
+    table: public.prices
+    structure: sorted_set
+    key: instrument
+    schedule: "@every 5m"
+  - name: prices
+    table: public.prices
+    structure: hash
+    schedule: "@every 5m"
+  - name: orders_stream
+    table: public.orders
+    structure: stream
+    schedule: "@every 10s"
+    tracking:
+      column: created_at
+      operator: "!="
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation to fail")
+	}
+
+	wants := []string{
+		`task "prices": value is required for structure "sorted_set"`,
+		`task "prices": score is required for structure "sorted_set"`,
+		`task "prices": duplicate name`,
+		`task "prices": unknown structure "hash"`,
+		`task "orders_stream": fields must not be empty`,
+		`task "orders_stream": tracking.operator "!=" not supported`,
+		`task "orders_stream": tracking.last_value_key is required`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidate_TrackingOnMapDoesNotRequireFields(t *testing.T) {
+	path := writeTempYAML(t, `
+tasks:
+  - name: recent_orders
+    table: orders
+    structure: map
+    key: id
+    value: status
+    schedule: "@every 30s"
+    tracking:
+      column: updated_at
+      operator: ">"
+      last_value_key: checkpoint:recent_orders
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+}
+
+func TestValidate_CompositeTracking(t *testing.T) {
+	path := writeTempYAML(t, `
+tasks:
+  - name: orders_stream
+    table: public.orders
+    structure: stream
+    fields: [id, updated_at]
+    schedule: "@every 10s"
+    tracking:
+      columns: [updated_at, id]
+      operator: ">"
+      last_value_key: checkpoint:orders_stream
+  - name: orders_both
+    table: public.orders
+    structure: stream
+    fields: [id, updated_at]
+    schedule: "@every 10s"
+    tracking:
+      column: updated_at
+      columns: [updated_at, version]
+      operator: ">"
+      last_value_key: checkpoint:orders_both
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation to fail")
+	}
+	if strings.Contains(err.Error(), `task "orders_stream"`) {
+		t.Errorf("composite cursor rejected: %v", err)
+	}
+	if want := `task "orders_both": tracking.column and tracking.columns are mutually exclusive`; !strings.Contains(err.Error(), want) {
+		t.Errorf("missing problem %q in:\n%v", want, err)
+	}
+}
+
+func TestValidate_Query(t *testing.T) {
+	path := writeTempYAML(t, `
+tasks:
+  - name: last_prices
+    query: SELECT instrument, max(px) AS px FROM quotes GROUP BY instrument
+    alias: prices
+    structure: map
+    key: instrument
+    value: px
+    schedule: "@every 10s"
+  - name: both
+    table: public.quotes
+    query: SELECT 1 AS x
+    where: x > 0
+    structure: set
+    value: x
+    schedule: "@every 10s"
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation to fail")
+	}
+	if strings.Contains(err.Error(), `task "last_prices"`) {
+		t.Errorf("valid query task rejected: %v", err)
+	}
+	for _, want := range []string{
+		`task "both": table and query are mutually exclusive`,
+		`task "both": where cannot be combined with query`,
+		`task "both": alias is required with query`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidate_Trigger(t *testing.T) {
+	path := writeTempYAML(t, `
+tasks:
+  - name: orders_stream
+    table: public.orders
+    fields: [id]
+    schedule: "@every 5m"
+    trigger:
+      listen: orders_changed
+      debounce: 500ms
+  - name: quotes_stream
+    table: public.quotes
+    fields: [id]
+    schedule: "@every 5m"
+    trigger:
+      listen: Quotes-Changed
+      debounce: soon
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if got := cfg.Tasks[0].Trigger.EffectiveDebounce(); got != 500*time.Millisecond {
+		t.Errorf("debounce: got %s want 500ms", got)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation to fail")
+	}
+	if strings.Contains(err.Error(), `task "orders_stream"`) {
+		t.Errorf("valid trigger rejected: %v", err)
+	}
+	for _, want := range []string{
+		`task "quotes_stream": trigger.listen "Quotes-Changed" must be a lower-case identifier`,
+		`task "quotes_stream": trigger.debounce "soon" is not a valid duration`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidate_CDC(t *testing.T) {
+	path := writeTempYAML(t, `
+tasks:
+  - name: orders_cdc
+    table: public.orders
+    structure: map
+    key: id
+    value: status
+    cdc:
+      slot: red_courier_orders
+      publication: red_courier
+      lsn_key: lsn:orders_cdc
+  - name: quotes_cdc
+    table: public.quotes
+    structure: list
+    value: px
+    schedule: "@every 5m"
+    tracking:
+      column: id
+      operator: ">"
+      last_value_key: checkpoint:quotes_cdc
+    cdc:
+      slot: Quotes
+      publication: red_courier
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation to fail")
+	}
+	if strings.Contains(err.Error(), `task "orders_cdc"`) {
+		t.Errorf("valid cdc task rejected: %v", err)
+	}
+	for _, want := range []string{
+		`task "quotes_cdc": schedule is not used with cdc`,
+		`task "quotes_cdc": cdc.slot "Quotes" must be a lower-case identifier`,
+		`task "quotes_cdc": cdc.lsn_key is required`,
+		`task "quotes_cdc": cdc is only supported for structures map, set, sorted_set, stream, row`,
+		`task "quotes_cdc": cdc cannot be combined with tracking`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidate_Encoding(t *testing.T) {
+	path := writeTempYAML(t, `
+tasks:
+  - name: orders_stream
+    table: public.orders
+    fields: [id, created_at]
+    schedule: "@every 5m"
+    encoding:
+      time_format: unix_ms
+      timezone: Europe/London
+      nulls: value
+      null_value: "\\N"
+  - name: quotes_stream
+    table: public.quotes
+    fields: [id]
+    schedule: "@every 5m"
+    encoding:
+      time_format: iso
+      timezone: Mars/Olympus
+      null_value: "-"
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation to fail")
+	}
+	if strings.Contains(err.Error(), `task "orders_stream"`) {
+		t.Errorf("valid encoding rejected: %v", err)
+	}
+	for _, want := range []string{
+		`task "quotes_stream": encoding: time_format "iso" is neither a known format nor a Go layout`,
+		`task "quotes_stream": encoding.null_value requires nulls "value"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidate_Stream(t *testing.T) {
+	path := writeTempYAML(t, `
+tasks:
+  - name: order_events
+    table: public.order_events
+    fields: [id, created_at, status]
+    schedule: "@every 5m"
+    stream:
+      max_age: 168h
+      id_column: created_at
+      id_sequence_column: id
+      dedup:
+        column: id
+        key: dedup:order_events
+        window: 1h
+  - name: quotes_stream
+    table: public.quotes
+    fields: [id]
+    schedule: "@every 5m"
+    stream:
+      max_len: 1000
+      max_age: forever
+      id_sequence_column: id
+      dedup:
+        window: 1h
+  - name: tracked_events
+    table: public.order_events
+    fields: [id, created_at]
+    schedule: "@every 5m"
+    tracking:
+      columns: [created_at, id]
+      operator: ">="
+      last_value_key: checkpoint:tracked_events
+    stream:
+      id_column: created_at
+      id_sequence_column: id
+  - name: shipments
+    table: public.shipments
+    fields: [id, created_at]
+    schedule: "@every 5m"
+    tracking:
+      column: updated_at
+      operator: ">"
+      last_value_key: checkpoint:shipments
+    stream:
+      id_column: created_at
+      id_sequence_column: id
+  - name: prices
+    table: public.prices
+    structure: set
+    value: px
+    schedule: "@every 5m"
+    stream:
+      max_len: 1000
+  - name: orders_cdc
+    table: public.orders
+    fields: [id, status]
+    stream:
+      max_len: 1000
+      id_column: id
+    cdc:
+      slot: red_courier_orders
+      publication: red_courier
+      lsn_key: lsn:orders_cdc
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation to fail")
+	}
+	for _, name := range []string{"order_events", "tracked_events"} {
+		if strings.Contains(err.Error(), `task "`+name+`"`) {
+			t.Errorf("valid stream settings rejected: %v", err)
+		}
+	}
+	for _, want := range []string{
+		`task "quotes_stream": stream.max_age "forever" is not a valid duration`,
+		`task "quotes_stream": stream.max_len and stream.max_age are mutually exclusive`,
+		`task "quotes_stream": stream.id_sequence_column requires stream.id_column`,
+		`task "orders_cdc": stream.id_column requires stream.id_sequence_column`,
+		`task "quotes_stream": stream.dedup.column is required`,
+		`task "quotes_stream": stream.dedup.key is required`,
+		`task "shipments": stream.id_column "created_at" must be the first tracking column`,
+		`task "shipments": stream.id_sequence_column "id" must be the second tracking column`,
+		`task "prices": stream is only valid with structure "stream"`,
+		`task "orders_cdc": stream.id_column cannot be combined with cdc`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidate_Overlap(t *testing.T) {
+	path := writeTempYAML(t, `
+scheduler:
+  max_concurrent_tasks: 50
+tasks:
+  - name: orders_stream
+    table: public.orders
+    fields: [id]
+    schedule: "@every 10s"
+    overlap: queue
+  - name: quotes_stream
+    table: public.quotes
+    fields: [id]
+    schedule: "@every 10s"
+    overlap: parallel
+  - name: prices_snapshot
+    table: public.prices
+    fields: [id]
+    structure: snapshot
+    schedule: "@every 10s"
+    overlap: allow
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation to fail")
+	}
+	if strings.Contains(err.Error(), `task "orders_stream"`) {
+		t.Errorf("valid overlap rejected: %v", err)
+	}
+	for _, want := range []string{
+		`scheduler.max_concurrent_tasks 50 must be between 1 and 10`,
+		`task "quotes_stream": unknown overlap "parallel" (must be one of skip, queue, allow)`,
+		`task "prices_snapshot": overlap allow is not supported for structure "snapshot"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidate_LeaderElection(t *testing.T) {
+	for _, c := range []struct {
+		yaml, want string
+	}{
+		{"enabled: true", ""},
+		{"enabled: true\n  ttl: 30s\n  renew_interval: 10s", ""},
+		{"enabled: true\n  ttl: 500ms", `leader_election.ttl "500ms" must be a duration of at least 1s`},
+		{"enabled: true\n  ttl: 10s\n  renew_interval: 8s", `leader_election.renew_interval 8s must be at most half of ttl 10s`},
+		{"enabled: true\n  renew_interval: soon", `leader_election.renew_interval "soon" is not a valid duration`},
+	} {
+		path := writeTempYAML(t, "leader_election:\n  "+c.yaml+"\n")
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		err = Validate(cfg)
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", c.yaml, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("%q: missing problem %q in %v", c.yaml, c.want, err)
+		}
+	}
+}
+
+func TestValidate_TaskLocks(t *testing.T) {
+	for _, c := range []struct {
+		yaml, want string
+	}{
+		{"scheduler:\n  task_locks:\n    enabled: true\n    ttl: 1m", ""},
+		{"scheduler:\n  task_locks:\n    enabled: true\n    ttl: 10ms", `scheduler.task_locks.ttl "10ms" must be a duration of at least 1s`},
+		{"scheduler:\n  task_locks:\n    enabled: true\nleader_election:\n  enabled: true", "scheduler.task_locks and leader_election cannot both be enabled"},
+		{"scheduler:\n  task_locks:\n    enabled: false\nleader_election:\n  enabled: true", ""},
+	} {
+		cfg, err := LoadConfig(writeTempYAML(t, c.yaml+"\n"))
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		err = Validate(cfg)
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", c.yaml, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("%q: missing problem %q in %v", c.yaml, c.want, err)
+		}
+	}
+}
+
+func TestValidate_FailurePolicy(t *testing.T) {
+	base := "tasks:\n  - name: orders\n    table: public.orders\n    structure: stream\n    fields: [id]\n    schedule: \"@every 1m\"\n"
+	for _, c := range []struct {
+		yaml, want string
+	}{
+		{"    retry:\n      max_attempts: 5\n    circuit_breaker:\n      failure_threshold: 3\n      pause: 30m", ""},
+		{"    retry: {}", ""},
+		{"    retry:\n      initial_backoff: 1m\n      max_backoff: 10s", "retry.initial_backoff 1m0s must not exceed max_backoff 10s"},
+		{"    retry:\n      max_attempts: -1", "retry.max_attempts -1 must be at least 1"},
+		{"    retry:\n      initial_backoff: soon", `retry.initial_backoff "soon" is not a valid duration`},
+		{"    circuit_breaker:\n      pause: -1m", `circuit_breaker.pause "-1m" is not a valid duration`},
+	} {
+		cfg, err := LoadConfig(writeTempYAML(t, base+c.yaml+"\n"))
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		err = Validate(cfg)
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", c.yaml, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("%q: missing problem %q in %v", c.yaml, c.want, err)
+		}
+	}
+}
+
+func TestValidate_Timing(t *testing.T) {
+	base := "tasks:\n  - name: orders\n    table: public.orders\n    structure: stream\n    fields: [id]\n"
+	for _, c := range []struct {
+		yaml, want string
+	}{
+		{"    schedule: \"0 9 * * 1-5\"\n    timezone: America/New_York\n    timeout: 5m\n    jitter: 30s", ""},
+		{"    schedule: \"@every 1m\"\n    jitter: 10s", ""},
+		{"    schedule: \"@every 1m\"\n    jitter: 1m", "jitter 1m0s must be shorter than the schedule interval 1m0s"},
+		{"    schedule: \"*/5 * * * *\"\n    jitter: 5m", "jitter 5m0s must be shorter than the schedule interval 5m0s"},
+		{"    schedule: \"0 9,10 * * 1-5\"\n    jitter: 2h", "jitter 2h0m0s must be shorter than the schedule interval 1h0m0s"},
+		{"    schedule: \"0 9 * * *\"\n    jitter: 2h", ""},
+		{"    schedule: \"@every 1m\"\n    jitter: -1s", `jitter "-1s" is not a valid duration`},
+		{"    schedule: \"@every 1m\"\n    timeout: 0s", `timeout "0s" is not a valid duration`},
+		{"    schedule: \"@every 1m\"\n    timezone: UTC", "timezone has no effect on @every schedules"},
+		{"    schedule: \"0 9 * * *\"\n    timezone: Mars/Olympus_Mons", `unknown timezone "Mars/Olympus_Mons"`},
+		{"    schedule: \"CRON_TZ=UTC 0 9 * * *\"\n    timezone: UTC", "timezone cannot be combined with a CRON_TZ= prefix in schedule"},
+	} {
+		cfg, err := LoadConfig(writeTempYAML(t, base+c.yaml+"\n"))
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		err = Validate(cfg)
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", c.yaml, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("%q: missing problem %q in %v", c.yaml, c.want, err)
+		}
+	}
+}
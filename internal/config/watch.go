@@ -0,0 +1,51 @@
+// internal/config/watch.go
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"log"
+	"os"
+	"time"
+)
+
+// Watch polls the config file every interval and calls onChange when its contents
+// change. Polling the contents (rather than filesystem events) keeps working when
+// Kubernetes updates a mounted ConfigMap by swapping symlinks. It returns when ctx
+// is cancelled.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	last, err := fileDigest(path)
+	if err != nil {
+		log.Printf("Config watch: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cur, err := fileDigest(path)
+			if err != nil {
+				// Transient while the file is being replaced; try again next tick.
+				continue
+			}
+			if !bytes.Equal(cur, last) {
+				last = cur
+				onChange()
+			}
+		}
+	}
+}
+
+func fileDigest(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
@@ -0,0 +1,30 @@
+// internal/config/watch_test.go
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatch_CallsOnChange(t *testing.T) {
+	path := writeTempYAML(t, "tasks: []\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go Watch(ctx, path, 10*time.Millisecond, func() { changed <- struct{}{} })
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("log_sql: true\ntasks: []\n"), 0o600); err != nil {
+		t.Fatalf("rewrite config: %v", err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected onChange after the file changed")
+	}
+}
@@ -16,8 +16,8 @@ type Database struct {
 
 func NewDatabase(cfg config.Config) (*Database, error) {
 	dsn := fmt.Sprintf(
-		"user=%s password=%s host=%s port=%d dbname=%s sslmode=%s pool_max_conns=10",
-		cfg.Postgres.User, cfg.Postgres.Password, cfg.Postgres.Host, cfg.Postgres.Port, cfg.Postgres.DBName, cfg.Postgres.SSLMode,
+		"user=%s password=%s host=%s port=%d dbname=%s sslmode=%s pool_max_conns=%d",
+		cfg.Postgres.User, cfg.Postgres.Password, cfg.Postgres.Host, cfg.Postgres.Port, cfg.Postgres.DBName, cfg.Postgres.SSLMode, config.PoolMaxConns,
 	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
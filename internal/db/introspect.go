@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	sqlbuilder "red-courier/internal/sql_builder"
+)
+
+// ColumnInfo describes a column of a table or view, as seen by Postgres.
+type ColumnInfo struct {
+	Name      string
+	Type      string // formatted type, e.g. "timestamp with time zone"
+	Orderable bool   // has a default btree operator class, so it works in ORDER BY and range predicates
+	Indexed   bool   // is the leading column of at least one index
+}
+
+// describeTableSQL lists a relation's columns with their type, whether the type can
+// be ordered (directly, through its domain base type, a binary-coercible cast, or the
+// generic array/enum operator classes) and whether an index leads with the column.
+const describeTableSQL = `
+SELECT a.attname,
+       format_type(a.atttypid, a.atttypmod),
+       EXISTS (
+         SELECT 1
+         FROM pg_opclass oc
+         JOIN pg_am am ON am.oid = oc.opcmethod
+         WHERE am.amname = 'btree' AND oc.opcdefault
+           AND (oc.opcintype IN (t.oid, t.typbasetype)
+             OR (oc.opcintype = 'anyarray'::regtype AND t.typcategory = 'A')
+             OR (oc.opcintype = 'anyenum'::regtype AND t.typtype = 'e')
+             OR EXISTS (SELECT 1 FROM pg_cast c
+                        WHERE c.castsource = t.oid AND c.casttarget = oc.opcintype AND c.castmethod = 'b'))
+       ),
+       EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = a.attrelid AND i.indkey[0] = a.attnum)
+FROM pg_attribute a
+JOIN pg_type t ON t.oid = a.atttypid
+WHERE a.attrelid = to_regclass($1) AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum`
+
+// DescribeTable returns the columns of a "schema.table" (or bare "table") keyed by name.
+// It fails when the relation does not exist.
+func (db *Database) DescribeTable(ctx context.Context, qualified string) (map[string]ColumnInfo, error) {
+	schema, table := sqlbuilder.SplitSchemaTable(qualified)
+	regclass := quoteIdent(schema) + "." + quoteIdent(table)
+
+	rows, err := db.Pool.Query(ctx, describeTableSQL, regclass)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe %s: %w", qualified, err)
+	}
+	defer rows.Close()
+
+	cols := make(map[string]ColumnInfo)
+	for rows.Next() {
+		var c ColumnInfo
+		if err := rows.Scan(&c.Name, &c.Type, &c.Orderable, &c.Indexed); err != nil {
+			return nil, fmt.Errorf("failed to describe %s: %w", qualified, err)
+		}
+		cols[c.Name] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to describe %s: %w", qualified, err)
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("table %s does not exist", qualified)
+	}
+	return cols, nil
+}
+
+// ReplicationInfo describes what streaming a table's changes through a publication needs.
+type ReplicationInfo struct {
+	WALLevel          string // server wal_level; must be "logical"
+	PublicationExists bool
+	Published         bool   // the table is part of the publication
+	ReplicaIdentity   string // pg_class.relreplident: 'd'efault, 'f'ull, 'i'ndex or 'n'othing
+}
+
+const describeReplicationSQL = `
+SELECT current_setting('wal_level'),
+       EXISTS (SELECT 1 FROM pg_publication WHERE pubname = $1),
+       EXISTS (SELECT 1 FROM pg_publication_tables pt
+               WHERE pt.pubname = $1 AND pt.schemaname = n.nspname AND pt.tablename = c.relname),
+       c.relreplident::text
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.oid = to_regclass($2)`
+
+// DescribeReplication reports whether the changes of a "schema.table" (or bare "table")
+// can be streamed through publication.
+func (db *Database) DescribeReplication(ctx context.Context, publication, qualified string) (ReplicationInfo, error) {
+	schema, table := sqlbuilder.SplitSchemaTable(qualified)
+	regclass := quoteIdent(schema) + "." + quoteIdent(table)
+
+	var info ReplicationInfo
+	err := db.Pool.QueryRow(ctx, describeReplicationSQL, publication, regclass).
+		Scan(&info.WALLevel, &info.PublicationExists, &info.Published, &info.ReplicaIdentity)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return info, fmt.Errorf("table %s does not exist", qualified)
+	}
+	if err != nil {
+		return info, fmt.Errorf("failed to describe replication of %s: %w", qualified, err)
+	}
+	return info, nil
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
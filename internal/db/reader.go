@@ -2,127 +2,192 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	_ "github.com/jackc/pgx/v5"
+	goredis "github.com/redis/go-redis/v9"
 	"log"
 	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
 	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
 	sqlbuilder "red-courier/internal/sql_builder"
-	"red-courier/internal/util"
 )
 
 //TODO extract SQL generation logic to separate package
 
-// FetchRows retrieves rows from the specified table based on the task configuration.
-// It applies any static WHERE clauses and tracking filters, and returns the results as a slice of maps.
-func (db *Database) FetchRows(ctx context.Context, taskCfg config.TaskConfig, redisClient *redis.RedisClient) ([]map[string]any, error) {
+// Page selects one slice of a task's rows.
+type Page struct {
+	Cursor   []any  // tracking cursor, one value per tracking column; nil means first run (no tracking predicate)
+	Operator string // overrides tracking.operator when set, e.g. strict ">" between pages
+	Limit    int    // maximum rows to return; 0 means unbounded
+}
+
+// StreamRows queries the specified table based on the task configuration, applying any
+// static WHERE clauses and tracking filters. Rows are sent on the returned stream as they
+// are read, at most one batch ahead of the consumer; the caller must Close it.
+func (db *Database) StreamRows(ctx context.Context, taskCfg config.TaskConfig, redisClient *redis.RedisClient) (*rowstream.Stream, error) {
+	cursor, err := ReadCheckpoint(ctx, taskCfg, redisClient)
+	if err != nil {
+		return nil, err
+	}
+	return db.StreamPage(ctx, taskCfg, Page{Cursor: cursor}), nil
+}
+
+// ReadCheckpoint returns the task's stored tracking cursor, one typed value per
+// tracking column, or nil if the task is untracked or has not completed a load yet.
+func ReadCheckpoint(ctx context.Context, taskCfg config.TaskConfig, redisClient *redis.RedisClient) ([]any, error) {
+	if taskCfg.Tracking == nil {
+		return nil, nil
+	}
+	val, err := redisClient.Client.Get(ctx, taskCfg.Tracking.LastValueKey).Result()
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return nil, fmt.Errorf("failed to fetch last value for tracking: %w", err)
+	}
+	if val == "" {
+		return nil, nil
+	}
+	return pgvalue.DecodeCursor(val, len(taskCfg.TrackingColumns()))
+}
+
+// FetchPage runs the task's query for a single page and returns the rows as maps.
+func (db *Database) FetchPage(ctx context.Context, taskCfg config.TaskConfig, page Page) ([]map[string]any, error) {
+	return db.StreamPage(ctx, taskCfg, page).Collect()
+}
+
+// StreamPage runs the task's query for a single page in the background and sends the
+// rows on the returned stream, buffering at most one batch_size of rows.
+func (db *Database) StreamPage(ctx context.Context, taskCfg config.TaskConfig, page Page) *rowstream.Stream {
+	return rowstream.New(ctx, taskCfg.EffectiveBatchSize(), func(ctx context.Context, emit func(map[string]any) error) error {
+		plan, err := PlanSelect(taskCfg, page)
+		if err != nil {
+			return err
+		}
+
+		logSQL := taskCfg.EffectiveLogSQL(db.LogSql)
+		if logSQL {
+			// Keep it structured and readable. Redact/limit args if needed.
+			//TODO make configurable to log full args
+			//TODO consider using a proper SQL formatter
+			//TODO consider logging to a file instead of stdout
+			//TODO consider using a proper structured logger like zap or logrus
+			redacted := make([]any, len(plan.Args))
+			for i, a := range plan.Args {
+				s := fmt.Sprint(a)
+				if len(s) > 256 {
+					s = s[:256] + "…(truncated)"
+				}
+				redacted[i] = s
+			}
+			log.Printf("[task:%s] SQL: %s  ARGS: %v", taskCfg.Name, plan.SQL, redacted)
+		}
+
+		rows, err := db.Pool.Query(ctx, plan.SQL, plan.Args...)
+		if err != nil {
+			return fmt.Errorf("query failed: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			values, err := rows.Values()
+			if err != nil {
+				return fmt.Errorf("failed to read row values: %w", err)
+			}
+			rowMap := make(map[string]any, len(values))
+			for i, fd := range rows.FieldDescriptions() {
+				rowMap[string(fd.Name)] = values[i]
+			}
+			if err := emit(rowMap); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
+
+// PlanSelect resolves the task's columns, table and tracking settings into a SELECT plan
+// for the given page. A nil page.Cursor plans a first run without the tracking predicate.
+func PlanSelect(taskCfg config.TaskConfig, page Page) (sqlbuilder.SelectPlan, error) {
 	cols := resolveColumns(taskCfg)
 	if len(cols) == 0 {
-		return nil, fmt.Errorf("no columns resolved for task: %s", taskCfg.Name)
+		return sqlbuilder.SelectPlan{}, fmt.Errorf("no columns resolved for task: %s", taskCfg.Name)
 	}
 
-	// Resolve schema.table and tracking context
-	var lastValPtr *string
 	var trackingSpec *sqlbuilder.TrackingSpec
 	if taskCfg.Tracking != nil {
-		resolvedCol := taskCfg.ResolveColumn(taskCfg.Tracking.Column)
 		trackingSpec = &sqlbuilder.TrackingSpec{
-			Column:       resolvedCol,
+			Columns:      taskCfg.TrackingColumns(),
 			Operator:     taskCfg.Tracking.Operator,
 			LastValueKey: taskCfg.Tracking.LastValueKey,
 		}
-
-		val, err := redisClient.Client.Get(ctx, taskCfg.Tracking.LastValueKey).Result()
-		if err != nil && err.Error() != "redis: nil" && val == "" {
-			return nil, fmt.Errorf("failed to fetch last value for tracking: %w", err)
-		}
-		if val != "" {
-			lastValPtr = &val
+		if page.Operator != "" {
+			trackingSpec.Operator = page.Operator
 		}
 	}
 
-	spec, _ := sqlbuilder.FromQualifiedTable(taskCfg.Table, cols, taskCfg.Where, trackingSpec, lastValPtr)
-	plan, err := sqlbuilder.BuildSelect(spec)
-	if err != nil {
-		return nil, err
+	if taskCfg.Query != "" {
+		return sqlbuilder.BuildQuery(sqlbuilder.QuerySpec{SQL: taskCfg.Query, Tracking: trackingSpec, LastTuple: page.Cursor})
 	}
 
-	logSQL := taskCfg.EffectiveLogSQL(db.LogSql)
-	if logSQL {
-		// Keep it structured and readable. Redact/limit args if needed.
-		//TODO make configurable to log full args
-		//TODO consider using a proper SQL formatter
-		//TODO consider logging to a file instead of stdout
-		//TODO consider using a proper structured logger like zap or logrus
-		redacted := make([]any, len(plan.Args))
-		for i, a := range plan.Args {
-			s := fmt.Sprint(a)
-			if len(s) > 256 {
-				s = s[:256] + "…(truncated)"
-			}
-			redacted[i] = s
-		}
-		log.Printf("[task:%s] SQL: %s  ARGS: %v", taskCfg.Name, plan.SQL, redacted)
-	}
+	spec, _ := sqlbuilder.FromQualifiedTable(taskCfg.Table, cols, taskCfg.Where, trackingSpec, nil)
+	spec.LastTuple = page.Cursor
+	spec.Limit = page.Limit
+	return sqlbuilder.BuildSelect(spec)
+}
 
-	rows, err := db.Pool.Query(ctx, plan.SQL, plan.Args...)
+// DescribeQuery prepares the task's custom query without running it and returns the
+// names of its result columns, so broken SQL is reported before the task is scheduled.
+func (db *Database) DescribeQuery(ctx context.Context, taskCfg config.TaskConfig) ([]string, error) {
+	plan, err := PlanSelect(taskCfg, Page{})
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
-	}
-	defer rows.Close()
-
-	var results []map[string]any
-	var maxVal any
-
-	for rows.Next() {
-		values, err := rows.Values()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read row values: %w", err)
-		}
-		rowMap := make(map[string]any, len(values))
-		for i, fd := range rows.FieldDescriptions() {
-			rowMap[string(fd.Name)] = values[i]
-		}
-		results = append(results, rowMap)
-
-		// compute first-run checkpoint if needed
-		if plan.FirstRun && plan.TrackingCol != "" {
-			v := rowMap[plan.TrackingCol]
-			if maxVal == nil || util.CompareAny(v, maxVal) > 0 {
-				maxVal = v
-			}
-		}
-	}
-	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
 
-	// Persist initial checkpoint if first run
-	if plan.FirstRun && maxVal != nil && taskCfg.Tracking != nil {
-		if maxStr, ok := util.ToRedisString(maxVal); ok {
-			if err := redisClient.SetString(ctx, taskCfg.Tracking.LastValueKey, maxStr); err != nil {
-				log.Printf("[task:%s] Failed to persist initial checkpoint: %v", taskCfg.Name, err)
-			} else {
-				log.Printf("[task:%s] Stored initial checkpoint: %s = %s", taskCfg.Name, taskCfg.Tracking.LastValueKey, maxStr)
-			}
-		}
+	sd, err := conn.Conn().Prepare(ctx, "", plan.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("prepare failed: %w", err)
+	}
+	names := make([]string, len(sd.Fields))
+	for i, fd := range sd.Fields {
+		names[i] = fd.Name
 	}
+	return names, nil
+}
 
-	return results, nil
+// RequiredColumns returns the result columns the task reads from each row.
+func RequiredColumns(taskCfg config.TaskConfig) []string {
+	return resolveColumns(taskCfg)
 }
 
 func resolveColumns(taskCfg config.TaskConfig) []string {
 	var logicalCols []string
-	switch taskCfg.Structure {
+	switch taskCfg.DataStructure() {
 	case "stream":
 		logicalCols = taskCfg.Fields
+	case "row":
+		logicalCols = append(append([]string{}, taskCfg.Fields...), taskCfg.Value)
+		if kt, err := config.ParseKeyTemplate(taskCfg.EffectiveKeyTemplate()); err == nil {
+			logicalCols = append(logicalCols, kt.Fields()...)
+		}
 	default:
 		logicalCols = []string{taskCfg.Key, taskCfg.Value, taskCfg.Score}
 	}
 
-	// Include tracking column
-	if taskCfg.Tracking != nil && taskCfg.Tracking.Column != "" {
-		logicalCols = append(logicalCols, taskCfg.Tracking.Column)
+	// Include tracking columns
+	if taskCfg.Tracking != nil {
+		logicalCols = append(logicalCols, taskCfg.Tracking.CursorColumns()...)
+	}
+	// Include the columns stream entry IDs and dedup keys are read from
+	if s := taskCfg.Stream; s != nil {
+		logicalCols = append(logicalCols, s.IDColumn, s.IDSequenceColumn)
+		if s.Dedup != nil {
+			logicalCols = append(logicalCols, s.Dedup.Column)
+		}
 	}
 
 	unique := make(map[string]struct{})
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"red-courier/internal/config"
+)
+
+func TestRequiredColumns_StreamOptions(t *testing.T) {
+	cfg := config.TaskConfig{
+		Structure: "stream",
+		Fields:    []string{"id", "status"},
+		ColumnMap: map[string]string{"created": "created_at"},
+		Stream: &config.StreamConfig{
+			IDColumn:         "created",
+			IDSequenceColumn: "id",
+			Dedup:            &config.DedupConfig{Column: "order_id", Key: "dedup:orders"},
+		},
+	}
+	want := []string{"id", "status", "created_at", "order_id"}
+	if got := RequiredColumns(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"fmt"
+
+	"red-courier/internal/config"
+	sqlbuilder "red-courier/internal/sql_builder"
+)
+
+// TriggerSQL returns SQL that makes Postgres NOTIFY the task's trigger channel after
+// every statement that changes its table. The trigger is statement-level, so a bulk
+// update sends one notification rather than one per row. Running it again replaces
+// the function and trigger.
+func TriggerSQL(taskCfg config.TaskConfig) (string, error) {
+	if taskCfg.Trigger == nil {
+		return "", fmt.Errorf("task %q has no trigger", taskCfg.Name)
+	}
+	if taskCfg.Table == "" {
+		return "", fmt.Errorf("task %q uses query; create triggers on its source tables by hand", taskCfg.Name)
+	}
+
+	channel := taskCfg.Trigger.Listen
+	schema, table := sqlbuilder.SplitSchemaTable(taskCfg.Table)
+	qualified := quoteIdent(schema) + "." + quoteIdent(table)
+	fn := quoteIdent(schema) + "." + quoteIdent("red_courier_notify_"+channel)
+	trigger := quoteIdent("red_courier_" + channel)
+
+	return fmt.Sprintf(`-- Red Courier: notify channel %[1]s when %[2]s changes (task %[3]q).
+CREATE OR REPLACE FUNCTION %[4]s() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+  PERFORM pg_notify('%[1]s', TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME);
+  RETURN NULL;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS %[5]s ON %[2]s;
+CREATE TRIGGER %[5]s
+  AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON %[2]s
+  FOR EACH STATEMENT EXECUTE FUNCTION %[4]s();
+`, channel, qualified, taskCfg.Name, fn, trigger), nil
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"red-courier/internal/config"
+)
+
+func TestTriggerSQL(t *testing.T) {
+	cfg := config.TaskConfig{
+		Name:    "orders_stream",
+		Table:   "sales.orders",
+		Trigger: &config.TriggerConfig{Listen: "orders_changed"},
+	}
+	sql, err := TriggerSQL(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`CREATE OR REPLACE FUNCTION "sales"."red_courier_notify_orders_changed"() RETURNS trigger`,
+		`PERFORM pg_notify('orders_changed', TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME);`,
+		`DROP TRIGGER IF EXISTS "red_courier_orders_changed" ON "sales"."orders";`,
+		`AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON "sales"."orders"`,
+		`FOR EACH STATEMENT EXECUTE FUNCTION "sales"."red_courier_notify_orders_changed"();`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("missing %q in:\n%s", want, sql)
+		}
+	}
+
+	cfg.Table, cfg.Query = "", "SELECT 1"
+	if _, err := TriggerSQL(cfg); err == nil {
+		t.Errorf("expected an error for a query task")
+	}
+}
@@ -0,0 +1,226 @@
+// Package leader elects one replica to run tasks through a lease held in Redis.
+//
+// A replica takes the lease with SET NX PX and, with it, a fencing token: a
+// counter incremented on every acquisition. The leader renews the lease before it
+// expires and gives it up on shutdown; followers retry at the renew interval, so
+// a leader that dies is replaced within one ttl. Writes that carry the token (see
+// WithFence) are rejected by Redis once a newer leader has been elected.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/redis"
+)
+
+// ErrFenced is returned for a write made with a fencing token that is no longer
+// the latest, i.e. by a replica that lost its lease.
+var ErrFenced = errors.New("lease lost to another replica")
+
+// Fence identifies the lease a write is made under: Key holds the latest token.
+type Fence struct {
+	Key   string
+	Token int64
+}
+
+type fenceKey struct{}
+
+// WithFence returns a context carrying f, for writes made while leading.
+func WithFence(ctx context.Context, f Fence) context.Context {
+	return context.WithValue(ctx, fenceKey{}, f)
+}
+
+// FenceFrom returns the fence carried by ctx, if any.
+func FenceFrom(ctx context.Context) (Fence, bool) {
+	f, ok := ctx.Value(fenceKey{}).(Fence)
+	return f, ok
+}
+
+// acquireScript takes the lease if it is free and issues the next fencing token.
+// KEYS[1] is the lease, KEYS[2] the token counter; ARGV is the holder and ttl (ms).
+var acquireScript = goredis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+  return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// renewScript extends the lease if ARGV[1] still holds it.
+var renewScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lease if ARGV[1] still holds it.
+var releaseScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Status is a snapshot of the election as seen by this replica.
+type Status struct {
+	ID        string    `json:"id"`                   // this replica
+	Leader    bool      `json:"leader"`               // this replica holds the lease
+	Holder    string    `json:"holder,omitempty"`     // replica holding the lease, if known
+	Token     int64     `json:"token,omitempty"`      // fencing token of this replica's lease
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // when this replica's lease lapses without renewal
+}
+
+// Elector campaigns for the lease on behalf of one replica.
+type Elector struct {
+	redis *redis.RedisClient
+	key   string
+	id    string
+	ttl   time.Duration
+	renew time.Duration
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New returns an elector for the lease at key. An empty id defaults to the
+// hostname and process id.
+func New(r *redis.RedisClient, key, id string, ttl, renew time.Duration) *Elector {
+	if id == "" {
+		id = DefaultID()
+	}
+	return &Elector{redis: r, key: key, id: id, ttl: ttl, renew: renew, status: Status{ID: id}}
+}
+
+// DefaultID names this replica by hostname (the pod name on Kubernetes) and pid.
+func DefaultID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "red-courier"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
+// Status returns the current state of the election.
+func (e *Elector) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+// Run campaigns until ctx ends. Each time the lease is won, lead is called with a
+// context carrying the fence, which is cancelled when the lease is lost; lead must
+// return once its context is done and it has stopped writing, as the lease is
+// released (or left to expire) as soon as it returns. The lease is released when
+// ctx ends.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	ticker := time.NewTicker(e.renew)
+	defer ticker.Stop()
+	for {
+		if token, err := e.acquire(ctx); err != nil {
+			log.Printf("Leader election: %v", err)
+		} else if token > 0 {
+			e.hold(ctx, token, lead)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// hold leads until the lease is lost or ctx ends.
+func (e *Elector) hold(ctx context.Context, token int64, lead func(ctx context.Context)) {
+	log.Printf("Leader election: %s acquired %s with token %d", e.id, e.key, token)
+	leadCtx, cancel := context.WithCancel(WithFence(ctx, Fence{Key: e.tokenKey(), Token: token}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+
+	ticker := time.NewTicker(e.renew)
+	defer ticker.Stop()
+	for held := true; held; {
+		select {
+		case <-ctx.Done():
+			held = false
+		case <-done:
+			held = false
+		case <-ticker.C:
+			held = e.keep(ctx, token)
+		}
+	}
+	cancel()
+	<-done
+
+	e.release()
+	e.mu.Lock()
+	e.status = Status{ID: e.id}
+	e.mu.Unlock()
+	log.Printf("Leader election: %s no longer leads", e.id)
+}
+
+// acquire takes the lease if it is free and returns its fencing token, or 0 when
+// another replica holds it.
+func (e *Elector) acquire(ctx context.Context) (int64, error) {
+	token, err := acquireScript.Run(ctx, e.redis.Client, []string{e.key, e.tokenKey()}, e.id, e.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("acquire %s: %w", e.key, err)
+	}
+	if token > 0 {
+		e.mu.Lock()
+		e.status = Status{ID: e.id, Leader: true, Holder: e.id, Token: token, ExpiresAt: time.Now().Add(e.ttl)}
+		e.mu.Unlock()
+		return token, nil
+	}
+	holder, err := e.redis.Client.Get(ctx, e.key).Result()
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return 0, fmt.Errorf("read %s: %w", e.key, err)
+	}
+	e.mu.Lock()
+	e.status = Status{ID: e.id, Holder: holder}
+	e.mu.Unlock()
+	return 0, nil
+}
+
+// keep renews the lease. It reports false once another replica holds it, or
+// once the lease has lapsed because renewals failed.
+func (e *Elector) keep(ctx context.Context, token int64) bool {
+	ok, err := renewScript.Run(ctx, e.redis.Client, []string{e.key}, e.id, e.ttl.Milliseconds()).Int64()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case err == nil && ok == 1:
+		e.status.ExpiresAt = time.Now().Add(e.ttl)
+		return true
+	case err == nil:
+		log.Printf("Leader election: %s lost %s (token %d)", e.id, e.key, token)
+		return false
+	}
+	// Redis is unreachable: keep leading while the lease is certainly still ours.
+	log.Printf("Leader election: renew %s: %v", e.key, err)
+	return time.Now().Add(e.renew).Before(e.status.ExpiresAt)
+}
+
+// release gives the lease up so a follower can take over without waiting for it
+// to expire.
+func (e *Elector) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := releaseScript.Run(ctx, e.redis.Client, []string{e.key}, e.id).Err(); err != nil {
+		log.Printf("Leader election: release %s: %v", e.key, err)
+	}
+}
+
+func (e *Elector) tokenKey() string {
+	return e.key + ":token"
+}
@@ -0,0 +1,115 @@
+package leader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"red-courier/internal/redis"
+)
+
+func newTestElector(t *testing.T, mr *miniredis.Miniredis, id string) *Elector {
+	t.Helper()
+	r := redis.NewRedisClient(redis.RedisConfig{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = r.Close() })
+	return New(r, "leader", id, time.Second, 20*time.Millisecond)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// campaign runs e in the background and records the fences it leads under.
+type campaign struct {
+	stop   context.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+	fences []Fence
+}
+
+func startCampaign(e *Elector) *campaign {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &campaign{stop: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		e.Run(ctx, func(ctx context.Context) {
+			f, _ := FenceFrom(ctx)
+			c.mu.Lock()
+			c.fences = append(c.fences, f)
+			c.mu.Unlock()
+			<-ctx.Done()
+		})
+	}()
+	return c
+}
+
+func (c *campaign) terms() []Fence {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Fence(nil), c.fences...)
+}
+
+func TestElector_OneLeaderAndFailover(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a, b := newTestElector(t, mr, "a"), newTestElector(t, mr, "b")
+
+	ca := startCampaign(a)
+	waitFor(t, func() bool { return a.Status().Leader })
+	cb := startCampaign(b)
+	defer func() { cb.stop(); <-cb.done }()
+
+	waitFor(t, func() bool { return b.Status().Holder == "a" })
+	if b.Status().Leader {
+		t.Fatalf("only one replica may lead")
+	}
+	if got := a.Status().Token; got != 1 {
+		t.Errorf("first lease token: got %d want 1", got)
+	}
+
+	// A leader that shuts down releases the lease; the follower takes over with a
+	// newer token well before the ttl would have expired.
+	start := time.Now()
+	ca.stop()
+	<-ca.done
+	if v, _ := mr.Get("leader"); v == "a" {
+		t.Errorf("lease should be released on shutdown")
+	}
+	waitFor(t, func() bool { return b.Status().Leader })
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("failover took %s", d)
+	}
+	if terms := cb.terms(); len(terms) != 1 || terms[0].Token != 2 || terms[0].Key != "leader:token" {
+		t.Errorf("follower should lead under token 2, got %+v", terms)
+	}
+}
+
+func TestElector_LosesLease(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a := newTestElector(t, mr, "a")
+	ca := startCampaign(a)
+	defer func() { ca.stop(); <-ca.done }()
+	waitFor(t, func() bool { return a.Status().Leader })
+
+	// Another replica took the lease (e.g. after a long pause): a stops leading.
+	mr.Set("leader", "b")
+	waitFor(t, func() bool { return !a.Status().Leader })
+	if a.Status().Holder != "" && a.Status().Holder != "b" {
+		t.Errorf("holder: got %q", a.Status().Holder)
+	}
+
+	// Once b's lease is gone, a leads again under a newer token.
+	mr.Del("leader")
+	waitFor(t, func() bool { return a.Status().Leader })
+	if terms := ca.terms(); len(terms) != 2 || terms[1].Token <= terms[0].Token {
+		t.Errorf("expected a second term with a newer token, got %+v", terms)
+	}
+}
@@ -0,0 +1,164 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/redis"
+)
+
+// lockScript takes a task lock if it is free. Unlike the leader's lease, the lock
+// holds the replica's id and the new fencing token, so that one acquisition never
+// renews or releases a later one made by the same replica. KEYS[1] is the lock,
+// KEYS[2] the token counter; ARGV is the replica id and ttl (ms).
+var lockScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  return 0
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], ARGV[1] .. ':' .. token, 'PX', ARGV[2])
+return token
+`)
+
+// unlockScript deletes the lock at KEYS[1] if ARGV[1] still holds it, first
+// marking the interval as claimed at KEYS[2] for ARGV[2] ms when that is positive.
+var unlockScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  if tonumber(ARGV[2]) > 0 then
+    redis.call('SET', KEYS[2], ARGV[1], 'PX', ARGV[2])
+  end
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Locker hands out per-task locks, so replicas share the tasks between them while
+// each task runs on one replica at a time. A task lock is a lease like the
+// leader's, keyed by task name and with its own fencing token; the lock of a
+// replica that dies mid-run expires after ttl.
+type Locker struct {
+	redis  *redis.RedisClient
+	prefix string
+	id     string
+	ttl    time.Duration
+}
+
+// NewLocker returns a locker whose locks are the keys prefix+task name. An empty
+// id defaults to the hostname and process id.
+func NewLocker(r *redis.RedisClient, prefix, id string, ttl time.Duration) *Locker {
+	if id == "" {
+		id = DefaultID()
+	}
+	return &Locker{redis: r, prefix: prefix, id: id, ttl: ttl}
+}
+
+// ID returns the name this replica holds locks under.
+func (l *Locker) ID() string {
+	return l.id
+}
+
+// Elector returns an elector for the named task's lock, for tasks that hold it
+// for as long as they run, such as CDC streams.
+func (l *Locker) Elector(name string) *Elector {
+	return New(l.redis, l.prefix+name, l.id, l.ttl, l.ttl/3)
+}
+
+// Lock is a task lock held by this replica. It is renewed in the background
+// until Unlock is called.
+type Lock struct {
+	locker *Locker
+	key    string
+	value  string // id:token, what the lock holds while this acquisition has it
+	token  int64
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// TryLock takes the named task's lock. It returns a nil Lock when another run
+// holds it. The returned context carries the lock's fence and is cancelled if the
+// lock is lost; Unlock must be called once the run ends.
+func (l *Locker) TryLock(ctx context.Context, name string) (*Lock, context.Context, error) {
+	key := l.prefix + name
+	token, err := lockScript.Run(ctx, l.redis.Client, []string{key, key + ":token"}, l.id, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, nil, fmt.Errorf("lock %s: %w", key, err)
+	}
+	if token == 0 {
+		return nil, nil, nil
+	}
+	runCtx, cancel := context.WithCancel(WithFence(ctx, Fence{Key: key + ":token", Token: token}))
+	lk := &Lock{locker: l, key: key, value: fmt.Sprintf("%s:%d", l.id, token), token: token, cancel: cancel, done: make(chan struct{})}
+	go lk.renew(runCtx)
+	return lk, runCtx, nil
+}
+
+// Token returns the lock's fencing token.
+func (lk *Lock) Token() int64 {
+	return lk.token
+}
+
+// Claimed reports whether a scheduled run of the task already claimed the current
+// interval (see Unlock). Only scheduled runs check it; it is read while holding
+// the lock, so a claim made by the previous holder is always seen.
+func (lk *Lock) Claimed(ctx context.Context) (bool, error) {
+	n, err := lk.locker.redis.Client.Exists(ctx, lk.claimKey()).Result()
+	if err != nil {
+		return false, fmt.Errorf("read %s: %w", lk.claimKey(), err)
+	}
+	return n > 0, nil
+}
+
+// Unlock stops renewing the lock and gives it up. With claim > 0 the interval is
+// first marked as claimed for that long, so scheduled runs of the task on other
+// replicas skip it; triggered runs are not affected.
+func (lk *Lock) Unlock(claim time.Duration) {
+	lk.cancel()
+	<-lk.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	l := lk.locker
+	if err := unlockScript.Run(ctx, l.redis.Client, []string{lk.key, lk.claimKey()}, lk.value, claim.Milliseconds()).Err(); err != nil {
+		log.Printf("Task lock: release %s: %v", lk.key, err)
+	}
+}
+
+func (lk *Lock) claimKey() string {
+	return lk.key + ":claimed"
+}
+
+// renew extends the lock until ctx ends, and cancels the run once the lock is
+// lost or has lapsed because renewals failed.
+func (lk *Lock) renew(ctx context.Context) {
+	defer close(lk.done)
+	l := lk.locker
+	every := l.ttl / 3
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	expires := time.Now().Add(l.ttl)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := renewScript.Run(ctx, l.redis.Client, []string{lk.key}, lk.value, l.ttl.Milliseconds()).Int64()
+		switch {
+		case err == nil && ok == 1:
+			expires = time.Now().Add(l.ttl)
+			continue
+		case err == nil:
+			log.Printf("Task lock: %s lost %s (token %d)", l.id, lk.key, lk.token)
+		case time.Now().Add(every).Before(expires):
+			log.Printf("Task lock: renew %s: %v", lk.key, err)
+			continue
+		default:
+			log.Printf("Task lock: %s lapsed: %v", lk.key, err)
+		}
+		lk.cancel()
+		return
+	}
+}
@@ -0,0 +1,133 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"red-courier/internal/redis"
+)
+
+func newTestLocker(t *testing.T, mr *miniredis.Miniredis, id string) *Locker {
+	t.Helper()
+	r := redis.NewRedisClient(redis.RedisConfig{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = r.Close() })
+	return NewLocker(r, "lock:", id, 300*time.Millisecond)
+}
+
+func TestLocker_OneHolderPerTask(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a, b := newTestLocker(t, mr, "a"), newTestLocker(t, mr, "b")
+	ctx := context.Background()
+
+	lk, runCtx, err := a.TryLock(ctx, "orders")
+	if err != nil || lk == nil {
+		t.Fatalf("TryLock: %v, %v", lk, err)
+	}
+	if f, ok := FenceFrom(runCtx); !ok || f.Key != "lock:orders:token" || f.Token != 1 {
+		t.Errorf("fence: got %+v, %v", f, ok)
+	}
+	if other, _, err := b.TryLock(ctx, "orders"); err != nil || other != nil {
+		t.Fatalf("a held lock must not be taken: %v, %v", other, err)
+	}
+	// Locks are per task: b can run another task meanwhile.
+	other, _, err := b.TryLock(ctx, "clients")
+	if err != nil || other == nil {
+		t.Fatalf("TryLock other task: %v, %v", other, err)
+	}
+	other.Unlock(0)
+
+	// The lock is renewed for as long as the run takes.
+	time.Sleep(400 * time.Millisecond)
+	if runCtx.Err() != nil {
+		t.Fatalf("run cancelled while holding the lock")
+	}
+	if v, _ := mr.Get("lock:orders"); v != "a:1" {
+		t.Fatalf("lock holder: got %q", v)
+	}
+
+	lk.Unlock(0)
+	if runCtx.Err() == nil {
+		t.Errorf("Unlock should end the run context")
+	}
+	if mr.Exists("lock:orders") {
+		t.Errorf("Unlock(0) should delete the lock")
+	}
+	lk, _, err = b.TryLock(ctx, "orders")
+	if err != nil || lk == nil || lk.Token() != 2 {
+		t.Fatalf("b should take the free lock with token 2: %v, %v", lk, err)
+	}
+	if claimed, err := lk.Claimed(ctx); err != nil || claimed {
+		t.Fatalf("Claimed before any claim: %v, %v", claimed, err)
+	}
+	lk.Unlock(time.Minute)
+	if mr.Exists("lock:orders") {
+		t.Errorf("Unlock(claim) should still release the lock")
+	}
+	if ttl := mr.TTL("lock:orders:claimed"); ttl <= 30*time.Second {
+		t.Errorf("Unlock(claim) should mark the interval claimed, ttl %s", ttl)
+	}
+	// The claim does not block the lock: a triggered run can still take it.
+	lk, _, err = a.TryLock(ctx, "orders")
+	if err != nil || lk == nil {
+		t.Fatalf("TryLock after a claim: %v, %v", lk, err)
+	}
+	defer lk.Unlock(0)
+	if claimed, err := lk.Claimed(ctx); err != nil || !claimed {
+		t.Errorf("Claimed after Unlock(claim): %v, %v", claimed, err)
+	}
+}
+
+func TestLocker_LostLockCancelsRun(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a := newTestLocker(t, mr, "a")
+
+	lk, runCtx, err := a.TryLock(context.Background(), "orders")
+	if err != nil || lk == nil {
+		t.Fatalf("TryLock: %v, %v", lk, err)
+	}
+
+	// The lock expired (e.g. after a long pause) and another replica took it.
+	mr.Set("lock:orders", "b")
+	select {
+	case <-runCtx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("run should be cancelled once its lock is lost")
+	}
+	lk.Unlock(0)
+	if v, _ := mr.Get("lock:orders"); v != "b" {
+		t.Errorf("Unlock must not release another replica's lock, holder %q", v)
+	}
+}
+
+func TestLocker_LapsedLockRetakenBySameReplica(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a := newTestLocker(t, mr, "a")
+	ctx := context.Background()
+
+	old, oldCtx, err := a.TryLock(ctx, "orders")
+	if err != nil || old == nil {
+		t.Fatalf("TryLock: %v, %v", old, err)
+	}
+	// The lock lapsed and a later run on the same replica took it.
+	mr.Del("lock:orders")
+	lk, _, err := a.TryLock(ctx, "orders")
+	if err != nil || lk == nil || lk.Token() != 2 {
+		t.Fatalf("retake: %v, %v", lk, err)
+	}
+	defer lk.Unlock(0)
+
+	select {
+	case <-oldCtx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("the old run should see its lock as lost")
+	}
+	old.Unlock(time.Minute)
+	if v, _ := mr.Get("lock:orders"); v != "a:2" {
+		t.Errorf("the old run's Unlock must not release the new lock, holder %q", v)
+	}
+	if mr.Exists("lock:orders:claimed") {
+		t.Errorf("the old run's Unlock must not claim the interval")
+	}
+}
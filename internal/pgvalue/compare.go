@@ -0,0 +1,161 @@
+package pgvalue
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Compare orders two non-NULL values the way Postgres orders the column they came
+// from: integers of any width, floats and numeric compare by value with each other,
+// timestamps (with or without time zone) and dates by instant, uuids bytewise and
+// text by byte order, which matches Postgres under the C collation. Values of
+// different kinds cannot be compared and return an error.
+func Compare(a, b any) (int, error) {
+	switch x := a.(type) {
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y), nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case y:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+
+	if x, ok := uuidBytes(a); ok {
+		if y, ok := uuidBytes(b); ok {
+			return bytes.Compare(x[:], y[:]), nil
+		}
+	}
+
+	if x, ok := asInt64(a); ok {
+		if y, ok := asInt64(b); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	x, xok := asRat(a)
+	y, yok := asRat(b)
+	if xok && yok {
+		return x.Cmp(y), nil
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+// CompareTuple compares two cursors column by column, like a SQL row comparison.
+func CompareTuple(a, b []any) (int, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("cannot compare cursors of %d and %d values", len(a), len(b))
+	}
+	for i := range a {
+		c, err := Compare(a[i], b[i])
+		if err != nil || c != 0 {
+			return c, err
+		}
+	}
+	return 0, nil
+}
+
+func uuidBytes(v any) ([16]byte, bool) {
+	switch u := v.(type) {
+	case [16]byte:
+		return u, true
+	case pgtype.UUID:
+		return u.Bytes, u.Valid
+	}
+	return [16]byte{}, false
+}
+
+func asInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint:
+		return int64(n), n <= math.MaxInt64
+	case uint64:
+		return int64(n), n <= math.MaxInt64
+	}
+	return 0, false
+}
+
+// asRat returns a number of any supported type as an exact rational.
+// NaN and infinities have no rational value and are not comparable.
+func asRat(v any) (*big.Rat, bool) {
+	if n, ok := asInt64(v); ok {
+		return new(big.Rat).SetInt64(n), true
+	}
+	switch n := v.(type) {
+	case uint:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint64:
+		return new(big.Rat).SetUint64(n), true
+	case float32:
+		return floatRat(float64(n))
+	case float64:
+		return floatRat(n)
+	case pgtype.Numeric:
+		if !n.Valid || n.NaN || n.InfinityModifier != pgtype.Finite || n.Int == nil {
+			return nil, false
+		}
+		r := new(big.Rat).SetInt(n.Int)
+		exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absInt32(n.Exp))), nil)
+		if n.Exp >= 0 {
+			return r.Mul(r, new(big.Rat).SetInt(exp)), true
+		}
+		return r.Quo(r, new(big.Rat).SetInt(exp)), true
+	}
+	return nil, false
+}
+
+func floatRat(f float64) (*big.Rat, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false
+	}
+	return new(big.Rat).SetFloat64(f), true
+}
+
+func absInt32(n int32) int32 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,82 @@
+package pgvalue
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func numeric(t *testing.T, s string) pgtype.Numeric {
+	t.Helper()
+	var n pgtype.Numeric
+	if err := n.Scan(s); err != nil {
+		t.Fatalf("Scan(%q): %v", s, err)
+	}
+	return n
+}
+
+func TestCompare(t *testing.T) {
+	ts := time.Date(2025, 9, 18, 13, 4, 5, 0, time.UTC)
+	ny, _ := time.LoadLocation("America/New_York")
+
+	cases := []struct {
+		name string
+		a, b any
+		want int
+	}{
+		{"int2 vs int8", int16(7), int64(7), 0},
+		{"int4 vs int8", int32(-1), int64(3), -1},
+		{"int8 beyond float precision", int64(9007199254740993), int64(9007199254740992), 1},
+		{"uint64 beyond int64", uint64(math.MaxUint64), int64(math.MaxInt64), 1},
+		{"float vs int", 2.5, int32(2), 1},
+		{"numeric vs int", numeric(t, "42.000"), int64(42), 0},
+		{"numeric fraction", numeric(t, "0.1"), numeric(t, "0.10001"), -1},
+		{"numeric large", numeric(t, "12345678901234567890"), numeric(t, "12345678901234567891"), -1},
+		{"numeric vs float", numeric(t, "1.5"), 1.25, 1},
+		{"timestamptz across zones", ts, ts.In(ny), 0},
+		{"timestamp", ts, ts.Add(time.Microsecond), -1},
+		{"uuid", [16]byte{1}, pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, -1},
+		{"text", "b", "a", 1},
+		{"bool", false, true, -1},
+	}
+	for _, c := range cases {
+		got, err := Compare(c.a, c.b)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: got %d want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCompare_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b any
+	}{
+		{"text vs int", "42", int64(42)},
+		{"time vs text", time.Now(), "2025-09-18"},
+		{"NaN", math.NaN(), 1.0},
+		{"numeric NaN", pgtype.Numeric{NaN: true, Valid: true}, int64(1)},
+	}
+	for _, c := range cases {
+		if _, err := Compare(c.a, c.b); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+	if _, err := CompareTuple([]any{int64(1)}, []any{int64(1), int64(2)}); err == nil {
+		t.Errorf("expected an error for cursors of different lengths")
+	}
+}
+
+func TestCompareTuple(t *testing.T) {
+	ts := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	got, err := CompareTuple([]any{ts, int64(7)}, []any{ts, int32(9)})
+	if err != nil || got != -1 {
+		t.Errorf("got %d, %v want -1", got, err)
+	}
+}
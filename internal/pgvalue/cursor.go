@@ -0,0 +1,143 @@
+package pgvalue
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Kinds stored with each checkpoint value, named after the Postgres type the value
+// is bound as when the checkpoint is read back.
+const (
+	kindInt     = "int8"
+	kindFloat   = "float8"
+	kindNumeric = "numeric"
+	kindTime    = "timestamptz"
+	kindUUID    = "uuid"
+	kindBool    = "bool"
+	kindText    = "text"
+)
+
+// EncodeCursor serialises tracking values for storage in Redis as a JSON array of
+// [kind, text] pairs, e.g. [["timestamptz","2025-09-18T00:00:00Z"],["int8","42"]],
+// so DecodeCursor can return each value with the Go type it was read with.
+func EncodeCursor(vals []any) (string, error) {
+	pairs := make([][2]string, len(vals))
+	for i, v := range vals {
+		if v == nil {
+			return "", fmt.Errorf("cannot store a NULL tracking value")
+		}
+		pairs[i] = encodeCursorValue(v)
+	}
+	b, err := json.Marshal(pairs)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func encodeCursorValue(v any) [2]string {
+	if n, ok := asInt64(v); ok {
+		return [2]string{kindInt, strconv.FormatInt(n, 10)}
+	}
+	switch val := v.(type) {
+	case uint, uint64:
+		// Only values above math.MaxInt64 get here, and those need numeric.
+		return [2]string{kindNumeric, Default.String(val)}
+	case float32:
+		return [2]string{kindFloat, strconv.FormatFloat(float64(val), 'g', -1, 32)}
+	case float64:
+		return [2]string{kindFloat, strconv.FormatFloat(val, 'g', -1, 64)}
+	case pgtype.Numeric:
+		return [2]string{kindNumeric, Default.String(val)}
+	case time.Time:
+		return [2]string{kindTime, val.Format(time.RFC3339Nano)}
+	case bool:
+		return [2]string{kindBool, strconv.FormatBool(val)}
+	case string:
+		return [2]string{kindText, val}
+	}
+	if u, ok := uuidBytes(v); ok {
+		return [2]string{kindUUID, formatUUID(u)}
+	}
+	// Anything else is bound as text and converted by Postgres.
+	return [2]string{kindText, Default.String(v)}
+}
+
+// DecodeCursor parses a checkpoint for a cursor of n columns into values ready to
+// bind as query parameters. Checkpoints written before values carried their kind
+// (a plain string, or a JSON array of strings for a composite cursor) decode to
+// strings, which Postgres converts to the column type.
+func DecodeCursor(s string, n int) ([]any, error) {
+	var pairs [][2]string
+	if strings.HasPrefix(s, "[[") && json.Unmarshal([]byte(s), &pairs) == nil {
+		if len(pairs) != n {
+			return nil, fmt.Errorf("checkpoint %q has %d values, want %d", s, len(pairs), n)
+		}
+		vals := make([]any, n)
+		for i, p := range pairs {
+			v, err := decodeCursorValue(p[0], p[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid checkpoint %q: %w", s, err)
+			}
+			vals[i] = v
+		}
+		return vals, nil
+	}
+
+	if n == 1 {
+		return []any{s}, nil
+	}
+	var parts []string
+	if err := json.Unmarshal([]byte(s), &parts); err != nil {
+		return nil, fmt.Errorf("invalid composite checkpoint %q: %w", s, err)
+	}
+	if len(parts) != n {
+		return nil, fmt.Errorf("composite checkpoint %q has %d values, want %d", s, len(parts), n)
+	}
+	vals := make([]any, n)
+	for i, p := range parts {
+		vals[i] = p
+	}
+	return vals, nil
+}
+
+func decodeCursorValue(kind, text string) (any, error) {
+	switch kind {
+	case kindInt:
+		return strconv.ParseInt(text, 10, 64)
+	case kindFloat:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil || math.IsNaN(f) {
+			return nil, fmt.Errorf("invalid float8 %q", text)
+		}
+		return f, nil
+	case kindNumeric:
+		var n pgtype.Numeric
+		if err := n.Scan(text); err != nil {
+			return nil, fmt.Errorf("invalid numeric %q: %w", text, err)
+		}
+		return n, nil
+	case kindTime:
+		return time.Parse(time.RFC3339Nano, text)
+	case kindUUID:
+		var u [16]byte
+		b, err := hex.DecodeString(strings.ReplaceAll(text, "-", ""))
+		if err != nil || len(b) != len(u) {
+			return nil, fmt.Errorf("invalid uuid %q", text)
+		}
+		copy(u[:], b)
+		return u, nil
+	case kindBool:
+		return strconv.ParseBool(text)
+	case kindText:
+		return text, nil
+	}
+	return nil, fmt.Errorf("unknown value kind %q", kind)
+}
@@ -0,0 +1,87 @@
+package pgvalue
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	ts := time.Date(2025, 9, 18, 13, 4, 5, 123456000, time.FixedZone("", -4*3600))
+	uuid := [16]byte{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+	vals := []any{
+		ts,
+		int32(42),
+		int64(math.MaxInt64),
+		uint64(math.MaxUint64),
+		0.1,
+		numeric(t, "12345678901234567890.000123"),
+		uuid,
+		"NEW",
+		true,
+	}
+
+	s, err := EncodeCursor(vals)
+	if err != nil {
+		t.Fatalf("EncodeCursor: %v", err)
+	}
+	got, err := DecodeCursor(s, len(vals))
+	if err != nil {
+		t.Fatalf("DecodeCursor(%s): %v", s, err)
+	}
+	for i := range vals {
+		cmp, err := Compare(got[i], vals[i])
+		if err != nil || cmp != 0 {
+			t.Errorf("value %d: got %#v (%T) want %#v, %v", i, got[i], got[i], vals[i], err)
+		}
+	}
+	if _, ok := got[1].(int64); !ok {
+		t.Errorf("int4 should decode as int64, got %T", got[1])
+	}
+	if _, ok := got[6].([16]byte); !ok {
+		t.Errorf("uuid should decode as [16]byte, got %T", got[6])
+	}
+}
+
+func TestEncodeCursor_Format(t *testing.T) {
+	s, err := EncodeCursor([]any{time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC), int64(42)})
+	if err != nil {
+		t.Fatalf("EncodeCursor: %v", err)
+	}
+	if want := `[["timestamptz","2025-09-18T00:00:00Z"],["int8","42"]]`; s != want {
+		t.Errorf("got %s want %s", s, want)
+	}
+	if _, err := EncodeCursor([]any{nil}); err == nil {
+		t.Errorf("expected an error for a NULL value")
+	}
+}
+
+func TestDecodeCursor_Legacy(t *testing.T) {
+	got, err := DecodeCursor("2025-09-18T00:00:00Z", 1)
+	if err != nil || len(got) != 1 || got[0] != "2025-09-18T00:00:00Z" {
+		t.Errorf("single value: got %v, %v", got, err)
+	}
+	got, err = DecodeCursor(`["2025-09-18T00:00:00Z","42"]`, 2)
+	if err != nil || len(got) != 2 || got[0] != "2025-09-18T00:00:00Z" || got[1] != "42" {
+		t.Errorf("composite: got %v, %v", got, err)
+	}
+}
+
+func TestDecodeCursor_Errors(t *testing.T) {
+	cases := []struct {
+		in string
+		n  int
+	}{
+		{`[["int8","42"]]`, 2},
+		{`[["int8","forty-two"]]`, 1},
+		{`[["uuid","not-a-uuid"]]`, 1},
+		{`[["money","$1"]]`, 1},
+		{`["only-one"]`, 2},
+		{`not json`, 2},
+	}
+	for _, c := range cases {
+		if _, err := DecodeCursor(c.in, c.n); err == nil {
+			t.Errorf("DecodeCursor(%s, %d): expected an error", c.in, c.n)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Package pgvalue converts values read from Postgres through pgx into the strings
+// written to Redis, so every loader and checkpoint renders a value the same way.
+package pgvalue
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How an Encoder writes NULL.
+const (
+	NullsEmpty = "empty" // as an empty string (default)
+	NullsSkip  = "skip"  // leave out the field, or the member or row it would name
+	NullsValue = "value" // as Encoder.NullValue
+)
+
+// NullModes lists the supported values for Encoder.Nulls.
+var NullModes = []string{NullsEmpty, NullsSkip, NullsValue}
+
+// How an Encoder writes bool.
+const (
+	BoolNumeric = "numeric" // as "1" or "0", like go-redis (default)
+	BoolText    = "text"    // as "true" or "false"
+)
+
+// BoolFormats lists the supported values for Encoder.BoolFormat.
+var BoolFormats = []string{BoolNumeric, BoolText}
+
+// Time formats accepted by ParseTimeFormat besides Go layouts.
+const (
+	FormatUnix      = "unix"    // seconds since the epoch
+	FormatUnixMilli = "unix_ms" // milliseconds since the epoch
+)
+
+var namedTimeFormats = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"date":        time.DateOnly,
+	"datetime":    time.DateTime,
+}
+
+// Encoder renders Postgres values as Redis strings. The zero value formats
+// timestamps as RFC 3339 with nanoseconds in their own zone, bools as "1" and "0"
+// and NULL as an empty string; a nil *Encoder behaves like Default.
+type Encoder struct {
+	TimeFormat string         // Go layout, FormatUnix or FormatUnixMilli; empty means RFC 3339 with nanoseconds
+	Location   *time.Location // timestamps are converted to this zone first; nil keeps their zone
+	Nulls      string         // NullsEmpty (default), NullsSkip or NullsValue
+	NullValue  string         // written for NULL with NullsValue
+	BoolFormat string         // BoolNumeric (default) or BoolText
+}
+
+// Default is the encoder for tasks without encoding settings, and for checkpoints.
+var Default = &Encoder{}
+
+// New returns an encoder for a task's settings. Empty settings keep the defaults.
+func New(timeFormat, timezone, nulls, nullValue, boolFormat string) (*Encoder, error) {
+	layout, err := ParseTimeFormat(timeFormat)
+	if err != nil {
+		return nil, err
+	}
+	e := &Encoder{TimeFormat: layout, Nulls: nulls, NullValue: nullValue, BoolFormat: boolFormat}
+	if timezone != "" {
+		if e.Location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", timezone)
+		}
+	}
+	switch nulls {
+	case "", NullsEmpty, NullsSkip, NullsValue:
+	default:
+		return nil, fmt.Errorf("unknown nulls %q (must be one of %s)", nulls, strings.Join(NullModes, ", "))
+	}
+	switch boolFormat {
+	case "", BoolNumeric, BoolText:
+	default:
+		return nil, fmt.Errorf("unknown bool_format %q (must be one of %s)", boolFormat, strings.Join(BoolFormats, ", "))
+	}
+	return e, nil
+}
+
+// ParseTimeFormat resolves a time format name (rfc3339, rfc3339nano, date, datetime,
+// unix, unix_ms) or a Go reference-time layout such as "2006-01-02 15:04".
+func ParseTimeFormat(s string) (string, error) {
+	if s == "" || s == FormatUnix || s == FormatUnixMilli {
+		return s, nil
+	}
+	if layout, ok := namedTimeFormats[s]; ok {
+		return layout, nil
+	}
+	// A layout without any reference-time element formats to itself.
+	if time.Unix(0, 0).UTC().Format(s) == s {
+		return "", fmt.Errorf("time_format %q is neither a known format nor a Go layout (e.g. \"2006-01-02 15:04:05\")", s)
+	}
+	return s, nil
+}
+
+// Encode returns the string written to Redis for v. ok is false when v is NULL
+// and NULLs are skipped.
+func (e *Encoder) Encode(v any) (string, bool) {
+	if e == nil {
+		e = Default
+	}
+	if !isNull(v) {
+		return e.String(v), true
+	}
+	switch e.Nulls {
+	case NullsSkip:
+		return "", false
+	case NullsValue:
+		return e.NullValue, true
+	}
+	return "", true
+}
+
+// Key returns the string for a value that names something in Redis: a key part,
+// a hash field or a member. NULL never names anything, so ok is false for it.
+func (e *Encoder) Key(v any) (string, bool) {
+	if isNull(v) {
+		return "", false
+	}
+	return e.String(v), true
+}
+
+// String renders a non-NULL value. Numbers are written in full precision without
+// exponents, uuids in their canonical form, json, jsonb and arrays as JSON, and
+// numeric, interval, time and other pgtype values in Postgres' text format.
+func (e *Encoder) String(v any) string {
+	if e == nil {
+		e = Default
+	}
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case bool:
+		if e.BoolFormat == BoolText {
+			return strconv.FormatBool(val)
+		}
+		if val {
+			return "1"
+		}
+		return "0"
+	case int:
+		return strconv.FormatInt(int64(val), 10)
+	case int8:
+		return strconv.FormatInt(int64(val), 10)
+	case int16:
+		return strconv.FormatInt(int64(val), 10)
+	case int32:
+		return strconv.FormatInt(int64(val), 10)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case uint:
+		return strconv.FormatUint(uint64(val), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(val), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(val), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(val), 10)
+	case uint64:
+		return strconv.FormatUint(val, 10)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case time.Time:
+		return e.formatTime(val)
+	case [16]byte:
+		return formatUUID(val)
+	case map[string]any, []any:
+		b, err := json.Marshal(e.jsonValue(val))
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	case driver.Valuer:
+		// pgtype.Numeric, Interval, Time, UUID, Date and friends render their
+		// Postgres text form (or a time.Time) through Value; an invalid one is NULL.
+		dv, err := val.Value()
+		if err == nil && dv == nil {
+			return ""
+		}
+		if err == nil {
+			if _, loops := dv.(driver.Valuer); !loops {
+				return e.String(dv)
+			}
+		}
+	case fmt.Stringer:
+		// netip.Prefix (inet, cidr), net.HardwareAddr (macaddr), pgtype.InfinityModifier
+		return val.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// isNull reports whether v is NULL: nil, or a pgtype value such as an invalid
+// pgtype.Numeric whose Value is nil.
+func isNull(v any) bool {
+	if v == nil {
+		return true
+	}
+	if dv, ok := v.(driver.Valuer); ok {
+		val, err := dv.Value()
+		return err == nil && val == nil
+	}
+	return false
+}
+
+func (e *Encoder) formatTime(t time.Time) string {
+	if e.Location != nil {
+		t = t.In(e.Location)
+	}
+	switch e.TimeFormat {
+	case "":
+		return t.Format(time.RFC3339Nano)
+	case FormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case FormatUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.Format(e.TimeFormat)
+}
+
+// jsonValue prepares a json/jsonb document or an array for json.Marshal: nested
+// values keep their JSON type, everything else is encoded like a scalar column.
+func (e *Encoder) jsonValue(v any) any {
+	switch val := v.(type) {
+	case nil, string, bool, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return val
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, x := range val {
+			out[k] = e.jsonValue(x)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, x := range val {
+			out[i] = e.jsonValue(x)
+		}
+		return out
+	}
+	if isNull(v) {
+		return nil
+	}
+	return e.String(v)
+}
+
+func formatUUID(b [16]byte) string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:16])
+	return string(buf[:])
+}
@@ -0,0 +1,134 @@
+package pgvalue
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestString(t *testing.T) {
+	var num pgtype.Numeric
+	if err := num.Scan("12345678901234567890.000123"); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	ts := time.Date(2025, 9, 18, 13, 4, 5, 120000000, time.UTC)
+
+	cases := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"text", "NEW", "NEW"},
+		{"bytea", []byte("raw"), "raw"},
+		{"bool", true, "1"},
+		{"invalid numeric", pgtype.Numeric{}, ""},
+		{"int2", int16(-7), "-7"},
+		{"int4", int32(42), "42"},
+		{"int8", int64(9007199254740993), "9007199254740993"},
+		{"float4", float32(0.1), "0.1"},
+		{"float8", 1e21, "1000000000000000000000"},
+		{"numeric", num, "12345678901234567890.000123"},
+		{"uuid", [16]byte{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}, "6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{"timestamptz", ts, "2025-09-18T13:04:05.12Z"},
+		{"interval", pgtype.Interval{Days: 1, Microseconds: 3600e6, Valid: true}, "1 day 01:00:00"},
+		{"time", pgtype.Time{Microseconds: (13*3600 + 4*60 + 5) * 1e6, Valid: true}, "13:04:05.000000"},
+		{"infinity", pgtype.Infinity, "infinity"},
+		{"inet", netip.MustParsePrefix("10.0.0.0/8"), "10.0.0.0/8"},
+		{"jsonb", map[string]any{"b": []any{1.5, nil}, "a": "x"}, `{"a":"x","b":[1.5,null]}`},
+		{"uuid[]", []any{[16]byte{1}, nil}, `["01000000-0000-0000-0000-000000000000",null]`},
+		{"numeric[]", []any{num, pgtype.Numeric{}}, `["12345678901234567890.000123",null]`},
+		{"timestamptz[]", []any{ts}, `["2025-09-18T13:04:05.12Z"]`},
+	}
+	for _, c := range cases {
+		if got := Default.String(c.in); got != c.want {
+			t.Errorf("%s: got %q want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestEncoder_TimeFormatAndZone(t *testing.T) {
+	ts := time.Date(2025, 9, 18, 13, 4, 5, 0, time.UTC)
+	cases := []struct {
+		format, zone, want string
+	}{
+		{"", "", "2025-09-18T13:04:05Z"},
+		{"rfc3339", "America/New_York", "2025-09-18T09:04:05-04:00"},
+		{"datetime", "Asia/Tokyo", "2025-09-18 22:04:05"},
+		{"date", "", "2025-09-18"},
+		{"unix", "", "1758200645"},
+		{"unix_ms", "", "1758200645000"},
+		{"02/01/2006 15h04", "", "18/09/2025 13h04"},
+	}
+	for _, c := range cases {
+		e, err := New(c.format, c.zone, "", "", "")
+		if err != nil {
+			t.Fatalf("New(%q, %q): %v", c.format, c.zone, err)
+		}
+		if got := e.String(ts); got != c.want {
+			t.Errorf("format %q zone %q: got %q want %q", c.format, c.zone, got, c.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"iso", ""}, {"", "Mars/Olympus"}} {
+		if _, err := New(bad[0], bad[1], "", "", ""); err == nil {
+			t.Errorf("New(%q, %q): expected an error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestEncoder_Nulls(t *testing.T) {
+	for _, c := range []struct {
+		nulls, nullValue string
+		want             string
+		ok               bool
+	}{
+		{"", "", "", true},
+		{NullsEmpty, "", "", true},
+		{NullsSkip, "", "", false},
+		{NullsValue, "NULL", "NULL", true},
+	} {
+		e, err := New("", "", c.nulls, c.nullValue, "")
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if got, ok := e.Encode(nil); got != c.want || ok != c.ok {
+			t.Errorf("nulls %q: got (%q, %v) want (%q, %v)", c.nulls, got, ok, c.want, c.ok)
+		}
+		if got, ok := e.Encode(pgtype.Numeric{}); got != c.want || ok != c.ok {
+			t.Errorf("nulls %q: invalid pgtype value: got (%q, %v) want (%q, %v)", c.nulls, got, ok, c.want, c.ok)
+		}
+		if _, ok := e.Key(nil); ok {
+			t.Errorf("nulls %q: NULL must never name a key", c.nulls)
+		}
+		if _, ok := e.Key(pgtype.Text{}); ok {
+			t.Errorf("nulls %q: an invalid pgtype value must never name a key", c.nulls)
+		}
+	}
+}
+
+func TestEncoder_BoolFormat(t *testing.T) {
+	for _, c := range []struct {
+		format  string
+		yes, no string
+	}{
+		{"", "1", "0"},
+		{BoolNumeric, "1", "0"},
+		{BoolText, "true", "false"},
+	} {
+		e, err := New("", "", "", "", c.format)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if got := e.String(true); got != c.yes {
+			t.Errorf("bool_format %q: true: got %q want %q", c.format, got, c.yes)
+		}
+		if got := e.String(false); got != c.no {
+			t.Errorf("bool_format %q: false: got %q want %q", c.format, got, c.no)
+		}
+	}
+	if _, err := New("", "", "", "", "yes_no"); err == nil {
+		t.Errorf("New: expected an error for an unknown bool_format")
+	}
+}
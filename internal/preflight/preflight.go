@@ -0,0 +1,146 @@
+// internal/preflight/preflight.go
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"red-courier/internal/config"
+	"red-courier/internal/db"
+	"red-courier/internal/redis/loader"
+	sqlbuilder "red-courier/internal/sql_builder"
+)
+
+// CheckConfig runs every static check that does not need a live Postgres or Redis:
+// config validation, loader construction and SQL planning for each task.
+// It returns all problems found rather than stopping at the first one.
+func CheckConfig(cfg *config.Config) []error {
+	var problems []error
+
+	if err := config.Validate(cfg); err != nil {
+		problems = append(problems, flatten(err)...)
+	}
+
+	for _, t := range cfg.Tasks {
+		if _, err := loader.NewLoader(t); err != nil {
+			problems = append(problems, fmt.Errorf("task %q: loader: %w", t.Name, err))
+		}
+
+		// Plan the first run, and the incremental run when tracking is configured,
+		// so both SQL shapes are exercised before the task is ever scheduled.
+		if _, err := db.PlanSelect(t, db.Page{}); err != nil {
+			problems = append(problems, fmt.Errorf("task %q: sql: %w", t.Name, err))
+			continue
+		}
+		if t.Tracking != nil {
+			sample := make([]any, len(t.TrackingColumns()))
+			for i := range sample {
+				sample[i] = "checkpoint"
+			}
+			if _, err := db.PlanSelect(t, db.Page{Cursor: sample, Limit: t.PageSize}); err != nil {
+				problems = append(problems, fmt.Errorf("task %q: sql: %w", t.Name, err))
+			}
+		}
+	}
+
+	return problems
+}
+
+// CheckDatabase runs the checks that need a live Postgres connection. Each task's table
+// must exist and contain every column the task reads, and its tracking columns must have
+// an orderable type; a custom query must prepare and return the columns the task reads.
+// CDC tasks must also be streamable through their publication.
+// Warnings, such as a tracking column without an index, do not stop the service.
+func CheckDatabase(ctx context.Context, pg *db.Database, cfg *config.Config) (problems, warnings []error) {
+	for _, t := range cfg.Tasks {
+		if t.Query != "" {
+			got, err := pg.DescribeQuery(ctx, t)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("task %q: query: %w", t.Name, err))
+				continue
+			}
+			for _, col := range db.RequiredColumns(t) {
+				if !slices.Contains(got, col) {
+					problems = append(problems, fmt.Errorf("task %q: query: result has no column %q (columns: %s)",
+						t.Name, col, strings.Join(got, ", ")))
+				}
+			}
+			continue
+		}
+
+		cols, err := pg.DescribeTable(ctx, t.Table)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("task %q: %w", t.Name, err))
+			continue
+		}
+		p, w := checkTable(t, cols)
+		problems = append(problems, p...)
+		warnings = append(warnings, w...)
+
+		if t.CDC != nil {
+			info, err := pg.DescribeReplication(ctx, t.CDC.Publication, t.Table)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("task %q: %w", t.Name, err))
+				continue
+			}
+			p, w := checkReplication(t, info)
+			problems = append(problems, p...)
+			warnings = append(warnings, w...)
+		}
+	}
+	return problems, warnings
+}
+
+// checkReplication checks that a CDC task's table can be streamed: the server decodes
+// WAL logically and the table is in the publication. Deletes from set and sorted_set
+// only carry the member when the table has REPLICA IDENTITY FULL.
+func checkReplication(t config.TaskConfig, info db.ReplicationInfo) (problems, warnings []error) {
+	if info.WALLevel != "logical" {
+		problems = append(problems, fmt.Errorf("task %q: cdc needs wal_level = logical (server has %s)", t.Name, info.WALLevel))
+	}
+	switch {
+	case !info.PublicationExists:
+		problems = append(problems, fmt.Errorf("task %q: publication %q does not exist (CREATE PUBLICATION %s FOR TABLE %s)",
+			t.Name, t.CDC.Publication, t.CDC.Publication, t.Table))
+	case !info.Published:
+		problems = append(problems, fmt.Errorf("task %q: %s is not in publication %q (ALTER PUBLICATION %s ADD TABLE %s)",
+			t.Name, t.Table, t.CDC.Publication, t.CDC.Publication, t.Table))
+	}
+	if (t.Structure == "set" || t.Structure == "sorted_set") && info.ReplicaIdentity != "f" {
+		warnings = append(warnings, fmt.Errorf("task %q: deleted rows will stay in %s unless %s has REPLICA IDENTITY FULL",
+			t.Name, t.EffectiveRedisKey(), t.Table))
+	}
+	return problems, warnings
+}
+
+// checkTable compares a task against the columns of its table.
+func checkTable(t config.TaskConfig, cols map[string]db.ColumnInfo) (problems, warnings []error) {
+	for _, col := range db.RequiredColumns(t) {
+		if _, ok := cols[sqlbuilder.PgName(col)]; !ok {
+			problems = append(problems, fmt.Errorf("task %q: column %q does not exist in %s", t.Name, col, t.Table))
+		}
+	}
+	for i, col := range t.TrackingColumns() {
+		info, ok := cols[sqlbuilder.PgName(col)]
+		if !ok {
+			continue
+		}
+		if !info.Orderable {
+			problems = append(problems, fmt.Errorf("task %q: tracking column %q has type %s, which cannot be ordered", t.Name, col, info.Type))
+		}
+		if i == 0 && !info.Indexed {
+			warnings = append(warnings, fmt.Errorf("task %q: tracking column %q has no index; every run will scan %s", t.Name, col, t.Table))
+		}
+	}
+	return problems, warnings
+}
+
+// flatten splits an errors.Join result back into its individual problems.
+func flatten(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
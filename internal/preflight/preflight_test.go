@@ -0,0 +1,155 @@
+// internal/preflight/preflight_test.go
+package preflight
+
+import (
+	"strings"
+	"testing"
+
+	"red-courier/internal/config"
+	"red-courier/internal/db"
+)
+
+func TestCheckConfig_Valid(t *testing.T) {
+	cfg := &config.Config{
+		Tasks: []config.TaskConfig{{
+			Name:      "orders_stream",
+			Table:     "public.orders",
+			Structure: "stream",
+			Fields:    []string{"id", "created_at"},
+			Schedule:  "@every 10s",
+			Tracking: &config.TrackingConfig{
+				Column:       "created_at",
+				Operator:     ">",
+				LastValueKey: "checkpoint:orders_stream",
+			},
+		}},
+	}
+	if problems := CheckConfig(cfg); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+}
+
+func TestCheckConfig_ReportsEveryTask(t *testing.T) {
+	cfg := &config.Config{
+		Tasks: []config.TaskConfig{
+			{
+				Name:      "bad_structure",
+				Table:     "public.orders",
+				Structure: "bogus",
+				Key:       "id",
+				Schedule:  "@every 10s",
+			},
+			{
+				Name:      "no_table",
+				Structure: "stream",
+				Fields:    []string{"id"},
+				Schedule:  "@every 10s",
+			},
+		},
+	}
+
+	problems := CheckConfig(cfg)
+	var joined []string
+	for _, p := range problems {
+		joined = append(joined, p.Error())
+	}
+	all := strings.Join(joined, "\n")
+
+	for _, want := range []string{`task "bad_structure": loader`, `task "no_table": sql`} {
+		if !strings.Contains(all, want) {
+			t.Errorf("expected a problem containing %q, got:\n%s", want, all)
+		}
+	}
+}
+
+func TestCheckConfig_QueryNeedsLastValue(t *testing.T) {
+	cfg := &config.Config{
+		Tasks: []config.TaskConfig{{
+			Name:      "orders_with_customer",
+			Query:     "SELECT o.id, o.updated_at, c.name FROM orders o JOIN customers c ON c.id = o.customer_id",
+			Alias:     "orders",
+			Structure: "stream",
+			Fields:    []string{"id", "updated_at", "name"},
+			Schedule:  "@every 10s",
+			Tracking: &config.TrackingConfig{
+				Column:       "updated_at",
+				Operator:     ">",
+				LastValueKey: "checkpoint:orders_with_customer",
+			},
+		}},
+	}
+
+	problems := CheckConfig(cfg)
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), ":last_value") {
+		t.Fatalf("expected a :last_value problem, got %v", problems)
+	}
+}
+
+func TestCheckTable(t *testing.T) {
+	task := config.TaskConfig{
+		Name:      "orders_stream",
+		Table:     "public.orders",
+		Structure: "stream",
+		Fields:    []string{"id", "Status", "amount", "payload"},
+		ColumnMap: map[string]string{"amount": "total_amont"},
+		Tracking: &config.TrackingConfig{
+			Columns:      []string{"payload", "id"},
+			Operator:     ">",
+			LastValueKey: "checkpoint:orders_stream",
+		},
+	}
+	cols := map[string]db.ColumnInfo{
+		"id":      {Name: "id", Type: "bigint", Orderable: true, Indexed: true},
+		"status":  {Name: "status", Type: "text", Orderable: true},
+		"payload": {Name: "payload", Type: "json"},
+	}
+
+	problems, warnings := checkTable(task, cols)
+	wantProblems := []string{
+		`task "orders_stream": column "total_amont" does not exist in public.orders`,
+		`task "orders_stream": tracking column "payload" has type json, which cannot be ordered`,
+	}
+	if len(problems) != len(wantProblems) {
+		t.Fatalf("got problems %v, want %v", problems, wantProblems)
+	}
+	for i, want := range wantProblems {
+		if problems[i].Error() != want {
+			t.Errorf("problem %d: got %q want %q", i, problems[i], want)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), `tracking column "payload" has no index`) {
+		t.Errorf("expected a missing index warning, got %v", warnings)
+	}
+}
+
+func TestCheckReplication(t *testing.T) {
+	task := config.TaskConfig{
+		Name:      "skus_cdc",
+		Table:     "public.skus",
+		Structure: "set",
+		Value:     "sku",
+		CDC:       &config.CDCConfig{Slot: "red_courier_skus", Publication: "red_courier", LSNKey: "lsn:skus"},
+	}
+
+	problems, warnings := checkReplication(task, db.ReplicationInfo{WALLevel: "replica", PublicationExists: true, ReplicaIdentity: "d"})
+	wantProblems := []string{
+		`task "skus_cdc": cdc needs wal_level = logical (server has replica)`,
+		`task "skus_cdc": public.skus is not in publication "red_courier" (ALTER PUBLICATION red_courier ADD TABLE public.skus)`,
+	}
+	if len(problems) != len(wantProblems) {
+		t.Fatalf("got problems %v, want %v", problems, wantProblems)
+	}
+	for i, want := range wantProblems {
+		if problems[i].Error() != want {
+			t.Errorf("problem %d: got %q want %q", i, problems[i], want)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "REPLICA IDENTITY FULL") {
+		t.Errorf("expected a replica identity warning, got %v", warnings)
+	}
+
+	problems, warnings = checkReplication(task, db.ReplicationInfo{WALLevel: "logical", PublicationExists: true, Published: true, ReplicaIdentity: "f"})
+	if len(problems) != 0 || len(warnings) != 0 {
+		t.Errorf("expected a clean check, got problems %v warnings %v", problems, warnings)
+	}
+}
@@ -0,0 +1,221 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/config"
+	"red-courier/internal/leader"
+	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
+)
+
+// queuedCmd is a command to write, with the range of rows it writes.
+type queuedCmd struct {
+	cmd      goredis.Cmder
+	firstRow int
+	lastRow  int
+	entryID  string // explicit ID of an XADD, which Redis may reject as a replay
+}
+
+// BatchError reports a failed pipeline batch. FirstRow and LastRow are indexes into
+// the rows streamed to Load; they are equal when the failing command wrote a single row.
+type BatchError struct {
+	Key      string
+	Command  string
+	FirstRow int
+	LastRow  int
+	Err      error
+}
+
+func (e *BatchError) Error() string {
+	if e.FirstRow == e.LastRow {
+		return fmt.Sprintf("%s %s failed at row %d: %v", e.Command, e.Key, e.FirstRow, e.Err)
+	}
+	return fmt.Sprintf("%s %s failed for rows %d-%d: %v", e.Command, e.Key, e.FirstRow, e.LastRow, e.Err)
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// writeBatches reads rows into batches of size rows as they arrive on the stream. For
+// each batch, queue builds the batch's commands (offset is the index of the batch's
+// first row), and the batch is sent in a single round trip. An error from
+// queue stops loading before the batch is written. Loading stops
+// at the first failed batch so rows are never written out of order, and fails with the
+// stream's error if the producer stopped early.
+//
+// When cp is non-nil the batch and the checkpoint are committed together by a Lua
+// script. Rows sharing a cursor value must never be split across a checkpoint, so
+// a batch whose last row shares its cursor with the next row is committed without
+// one; the checkpoint moves once the last batch of that group is written. A run
+// that fails part way through such a group re-sends the group's earlier rows on
+// the next run.
+func writeBatches(ctx context.Context, r *redis.RedisClient, key string, rows *rowstream.Stream, size int, cp *checkpoint,
+	queue func(batch []map[string]any, offset int) ([]queuedCmd, error)) error {
+	if size <= 0 {
+		size = config.DefaultBatchSize
+	}
+	next, more := rows.Next()
+	for start := 0; more; {
+		batch := make([]map[string]any, 0, size)
+		for more && len(batch) < size {
+			batch = append(batch, next)
+			next, more = rows.Next()
+		}
+		end := start + len(batch)
+		commitCp := cp
+		if cp != nil && more && cp.sameCursor(batch[len(batch)-1], next) {
+			commitCp = nil
+		}
+
+		cmds, err := queue(batch, start)
+		if err != nil {
+			return err
+		}
+		if cp != nil {
+			if err = cp.observe(batch); err != nil {
+				return err
+			}
+		}
+		err = commit(ctx, r, key, cmds, commitCp, start, end)
+		if err != nil {
+			return err
+		}
+		start = end
+	}
+	return rows.Err()
+}
+
+func execBatch(ctx context.Context, pipe goredis.Pipeliner, key string, cmds []queuedCmd, start, end int) error {
+	if _, err := pipe.Exec(ctx); err != nil {
+		for _, q := range cmds {
+			if q.cmd.Err() != nil {
+				return &BatchError{Key: key, Command: strings.ToUpper(q.cmd.Name()), FirstRow: q.firstRow, LastRow: q.lastRow, Err: q.cmd.Err()}
+			}
+		}
+		return &BatchError{Key: key, Command: "pipeline", FirstRow: start, LastRow: end - 1, Err: err}
+	}
+	return nil
+}
+
+// logReplayed reports stream entries that were not written again because the
+// stream already holds them, or held them before it was trimmed.
+func logReplayed(key string, n int64) {
+	if n > 0 {
+		log.Printf("Stream %s: skipped %d entries it already holds", key, n)
+	}
+}
+
+// replayedEntryErr is the tail of the error Redis returns for an XADD whose
+// explicit ID is not above the stream's last entry.
+const replayedEntryErr = "equal or smaller than the target stream top item"
+
+// commit writes a batch's commands, and the checkpoint when cp holds a value. Under
+// a fence (see leader.WithFence), with a checkpoint or with explicit stream entry
+// IDs, everything is applied by one script call, which writes nothing once another
+// replica holds the lease or lock the write was made under, and tells replayed
+// entries from rows that arrived out of ID order. Otherwise the commands are
+// pipelined.
+func commit(ctx context.Context, r *redis.RedisClient, key string, cmds []queuedCmd, cp *checkpoint, start, end int) error {
+	fence, fenced := leader.FenceFrom(ctx)
+	checkpointed := cp != nil && cp.value != nil
+	if !fenced && !checkpointed && !slices.ContainsFunc(cmds, func(q queuedCmd) bool { return q.entryID != "" }) {
+		if len(cmds) == 0 {
+			return nil
+		}
+		pipe := r.Client.Pipeline()
+		for _, q := range cmds {
+			_ = pipe.Process(ctx, q.cmd)
+		}
+		return execBatch(ctx, pipe, key, cmds, start, end)
+	}
+	if len(cmds) == 0 && !checkpointed {
+		return nil
+	}
+
+	var keys []string
+	argv := []any{"", ""}
+	if fenced {
+		keys = append(keys, fence.Key)
+		argv[0] = fence.Token
+	}
+	if checkpointed {
+		val, err := cp.encoded()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, cp.key)
+		argv[1] = val
+	}
+	seen := make(map[string]bool)
+	for _, q := range cmds {
+		args := q.cmd.Args()
+		for _, k := range commandKeys(args) {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		argv = append(argv, len(args), q.entryID)
+		argv = append(argv, args...)
+	}
+
+	res, err := commitScript.Run(ctx, r.Client, keys, argv...).Slice()
+	if err != nil {
+		return &BatchError{Key: key, Command: "EVAL", FirstRow: start, LastRow: end - 1, Err: err}
+	}
+	if idx, _ := res[0].(int64); idx < 0 {
+		return &BatchError{Key: key, Command: "EVAL", FirstRow: start, LastRow: end - 1, Err: leader.ErrFenced}
+	}
+	if idx, _ := res[0].(int64); idx > 0 {
+		q := cmds[idx-1]
+		msg, _ := res[1].(string)
+		return &BatchError{Key: key, Command: strings.ToUpper(q.cmd.Name()), FirstRow: q.firstRow, LastRow: q.lastRow, Err: errors.New(msg)}
+	}
+	if len(res) > 2 {
+		n, _ := res[2].(int64)
+		logReplayed(key, n)
+	}
+	return nil
+}
+
+// commandKeys returns the keys a queued command writes, so the script declares
+// every key it touches: the first argument, every argument of DEL, and both of
+// RENAME.
+func commandKeys(args []any) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	last := 2
+	switch name, _ := args[0].(string); strings.ToLower(name) {
+	case "del":
+		last = len(args)
+	case "rename":
+		last = min(3, len(args))
+	}
+	keys := make([]string, 0, last-1)
+	for _, a := range args[1:last] {
+		keys = append(keys, fmt.Sprint(a))
+	}
+	return keys
+}
+
+// write runs single commands outside a batch of rows, such as a snapshot's RENAME,
+// with the same fencing as commit.
+func write(ctx context.Context, r *redis.RedisClient, key string, cmds ...goredis.Cmder) error {
+	queued := make([]queuedCmd, len(cmds))
+	for i, cmd := range cmds {
+		queued[i] = queuedCmd{cmd: cmd}
+	}
+	err := commit(ctx, r, key, queued, nil, 0, 1)
+	var be *BatchError
+	if errors.As(err, &be) {
+		return be.Err
+	}
+	return err
+}
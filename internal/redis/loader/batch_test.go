@@ -0,0 +1,93 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"red-courier/internal/config"
+	"red-courier/internal/rowstream"
+)
+
+func TestLoaders_WriteAllRowsAcrossBatches(t *testing.T) {
+	mr, r := newTestRedis(t)
+	ctx := context.Background()
+
+	var rows []map[string]any
+	for i := 0; i < 25; i++ {
+		rows = append(rows, map[string]any{"id": int64(i), "name": fmt.Sprintf("n%d", i), "score": float64(i)})
+	}
+
+	tasks := []config.TaskConfig{
+		{Name: "m", Alias: "m", Structure: "map", Key: "id", Value: "name", BatchSize: 10},
+		{Name: "s", Alias: "s", Structure: "set", Value: "id", BatchSize: 10},
+		{Name: "z", Alias: "z", Structure: "sorted_set", Value: "name", Score: "score", BatchSize: 10},
+		{Name: "l", Alias: "l", Structure: "list", Value: "id", BatchSize: 10},
+		{Name: "x", Alias: "x", Structure: "stream", Fields: []string{"id", "name"}, BatchSize: 10},
+	}
+	for _, cfg := range tasks {
+		ld, err := NewLoader(cfg)
+		if err != nil {
+			t.Fatalf("NewLoader(%s): %v", cfg.Structure, err)
+		}
+		if err := ld.Load(ctx, rowstream.FromSlice(rows), cfg, r); err != nil {
+			t.Fatalf("Load(%s): %v", cfg.Structure, err)
+		}
+	}
+
+	if keys, _ := mr.HKeys("m"); len(keys) != 25 {
+		t.Errorf("map: got %d fields want 25", len(keys))
+	}
+	if members, _ := mr.Members("s"); len(members) != 25 {
+		t.Errorf("set: got %d members want 25", len(members))
+	}
+	if members, _ := mr.ZMembers("z"); len(members) != 25 {
+		t.Errorf("sorted_set: got %d members want 25", len(members))
+	}
+	list, _ := mr.List("l")
+	if len(list) != 25 || list[0] != "24" {
+		t.Errorf("list: got %d items, head %v; want 25 items, head 24", len(list), list)
+	}
+	if entries, _ := mr.Stream("x"); len(entries) != 25 {
+		t.Errorf("stream: got %d entries want 25", len(entries))
+	}
+}
+
+func TestLoaders_BatchErrorReportsRow(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	cfg := config.TaskConfig{
+		Name:        "orders_by_id",
+		Structure:   "row",
+		KeyTemplate: "order:{id}",
+		Fields:      []string{"id", "status"},
+		BatchSize:   4,
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	// order:6 already holds a string, so HSET on it fails with WRONGTYPE.
+	if err := mr.Set("order:6", "not a hash"); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	var rows []map[string]any
+	for i := 0; i < 10; i++ {
+		rows = append(rows, map[string]any{"id": int64(i), "status": "NEW"})
+	}
+
+	err = ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r)
+	var be *BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("expected *BatchError, got %v", err)
+	}
+	if be.FirstRow != 6 || be.LastRow != 6 || be.Command != "HSET" {
+		t.Errorf("unexpected batch error: %+v", be)
+	}
+	if mr.Exists("order:8") {
+		t.Errorf("rows after the failed batch should not be written")
+	}
+}
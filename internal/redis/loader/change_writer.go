@@ -0,0 +1,208 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
+	"red-courier/internal/redis"
+)
+
+// Change operations read from logical replication.
+const (
+	OpInsert   = "insert"
+	OpUpdate   = "update"
+	OpDelete   = "delete"
+	OpTruncate = "truncate"
+)
+
+// Change is one row-level change to a CDC task's table, keyed by column name.
+type Change struct {
+	Op  string
+	Row map[string]any // new row for insert and update; the old key (or row) for delete
+	Old map[string]any // previous key (or row) of an update, when Postgres sent one
+}
+
+// ChangeWriter applies row-level changes to a task's structure: inserts and updates
+// write the row like a polling run would, deletes remove what the row wrote, and
+// streams get one entry per change with an "op" field.
+type ChangeWriter struct {
+	cfg  config.TaskConfig
+	key  string
+	enc  *pgvalue.Encoder
+	tmpl config.KeyTemplate // structure "row" only
+}
+
+func NewChangeWriter(cfg config.TaskConfig) (*ChangeWriter, error) {
+	if cfg.CDC == nil {
+		return nil, fmt.Errorf("task %s has no cdc settings", cfg.Name)
+	}
+	enc, err := cfg.Encoder()
+	if err != nil {
+		return nil, err
+	}
+	w := &ChangeWriter{cfg: cfg, key: cfg.EffectiveRedisKey(), enc: enc}
+	switch cfg.Structure {
+	case "map", "set", "sorted_set", "stream":
+	case "row":
+		tmpl, err := config.ParseKeyTemplate(cfg.EffectiveKeyTemplate())
+		if err != nil {
+			return nil, err
+		}
+		w.tmpl = tmpl
+		w.key = cfg.EffectiveKeyTemplate()
+	default:
+		return nil, fmt.Errorf("cdc does not support structure %s", cfg.Structure)
+	}
+	return w, nil
+}
+
+// Apply writes changes in a single script call. When lsn is not empty it is stored
+// at cdc.lsn_key in the same call, so the data and the position it was read up to
+// are never out of step; partial flushes of a large transaction (an empty lsn) are
+// fenced like the rest. Row indexes in a BatchError are indexes into changes.
+func (w *ChangeWriter) Apply(ctx context.Context, r *redis.RedisClient, changes []Change, lsn string) error {
+	var cmds []queuedCmd
+	for i, c := range changes {
+		for _, cmd := range w.commands(ctx, c) {
+			cmds = append(cmds, queuedCmd{cmd: cmd, firstRow: i, lastRow: i})
+		}
+	}
+
+	cp := &checkpoint{key: w.cfg.CDC.LSNKey}
+	if lsn != "" {
+		cp.value = []any{lsn}
+	}
+	return commit(ctx, r, w.key, cmds, cp, 0, len(changes))
+}
+
+// commands builds the commands for one change. An update whose key changed first
+// removes what the old row wrote; a row missing a needed column writes nothing.
+func (w *ChangeWriter) commands(ctx context.Context, c Change) []goredis.Cmder {
+	if w.cfg.Structure == "stream" {
+		return w.streamCommands(ctx, c)
+	}
+	switch c.Op {
+	case OpTruncate:
+		if w.cfg.Structure == "row" {
+			// Per-row keys cannot be enumerated from the change; they are left in place.
+			return nil
+		}
+		return []goredis.Cmder{goredis.NewIntCmd(ctx, "del", w.key)}
+	case OpDelete:
+		return w.remove(ctx, c.Row)
+	}
+
+	var cmds []goredis.Cmder
+	if c.Old != nil && w.identity(c.Old) != w.identity(c.Row) {
+		cmds = append(cmds, w.remove(ctx, c.Old)...)
+	}
+	return append(cmds, w.upsert(ctx, c.Row)...)
+}
+
+func (w *ChangeWriter) streamCommands(ctx context.Context, c Change) []goredis.Cmder {
+	fields := map[string]any{"op": c.Op}
+	for _, logical := range w.cfg.Fields {
+		if val, ok := columnValue(w.enc, w.cfg, c.Row, logical); ok {
+			fields[logical] = val
+		}
+	}
+	args := &goredis.XAddArgs{Stream: w.key, Values: fields}
+	trimStream(args, w.cfg.Stream, time.Now())
+	return []goredis.Cmder{xaddCmd(ctx, args)}
+}
+
+func (w *ChangeWriter) upsert(ctx context.Context, row map[string]any) []goredis.Cmder {
+	col := func(name string) (string, bool) {
+		return columnValue(w.enc, w.cfg, row, name)
+	}
+	switch w.cfg.Structure {
+	case "map":
+		k, kOk := columnKey(w.enc, w.cfg, row, w.cfg.Key)
+		v, vOk := col(w.cfg.Value)
+		if kOk && vOk {
+			return []goredis.Cmder{goredis.NewIntCmd(ctx, "hset", w.key, k, v)}
+		}
+	case "set":
+		if v, ok := col(w.cfg.Value); ok {
+			return []goredis.Cmder{goredis.NewIntCmd(ctx, "sadd", w.key, v)}
+		}
+	case "sorted_set":
+		v, vOk := col(w.cfg.Value)
+		if score, ok := scoreOf(row[w.cfg.ResolveColumn(w.cfg.Score)]); vOk && ok {
+			return []goredis.Cmder{zaddCmd(ctx, w.key, goredis.Z{Score: score, Member: v})}
+		}
+	case "row":
+		key, ok := w.rowKey(row)
+		if !ok {
+			return nil
+		}
+		if w.cfg.Value != "" {
+			if v, ok := col(w.cfg.Value); ok {
+				return []goredis.Cmder{goredis.NewStatusCmd(ctx, "set", key, v)}
+			}
+			return nil
+		}
+		fields := make(map[string]any, len(w.cfg.Fields))
+		for _, logical := range w.cfg.Fields {
+			if v, ok := col(logical); ok {
+				fields[logical] = v
+			}
+		}
+		if len(fields) > 0 {
+			return []goredis.Cmder{hsetCmd(ctx, key, fields)}
+		}
+	}
+	return nil
+}
+
+// remove builds the command that undoes what row wrote. For set and sorted_set the
+// row must carry the value column, which deletes only do under REPLICA IDENTITY FULL
+// (or when the value is part of the replica identity).
+func (w *ChangeWriter) remove(ctx context.Context, row map[string]any) []goredis.Cmder {
+	switch w.cfg.Structure {
+	case "map":
+		if k, ok := columnKey(w.enc, w.cfg, row, w.cfg.Key); ok {
+			return []goredis.Cmder{goredis.NewIntCmd(ctx, "hdel", w.key, k)}
+		}
+	case "set":
+		if v, ok := columnValue(w.enc, w.cfg, row, w.cfg.Value); ok {
+			return []goredis.Cmder{goredis.NewIntCmd(ctx, "srem", w.key, v)}
+		}
+	case "sorted_set":
+		if v, ok := columnValue(w.enc, w.cfg, row, w.cfg.Value); ok {
+			return []goredis.Cmder{goredis.NewIntCmd(ctx, "zrem", w.key, v)}
+		}
+	case "row":
+		if key, ok := w.rowKey(row); ok {
+			return []goredis.Cmder{goredis.NewIntCmd(ctx, "del", key)}
+		}
+	}
+	return nil
+}
+
+// identity returns what names the row in Redis: the hash field, member or key.
+// An update that changes it must remove the old entry before writing the new one.
+func (w *ChangeWriter) identity(row map[string]any) string {
+	switch w.cfg.Structure {
+	case "map":
+		k, _ := columnKey(w.enc, w.cfg, row, w.cfg.Key)
+		return k
+	case "set", "sorted_set":
+		v, _ := columnValue(w.enc, w.cfg, row, w.cfg.Value)
+		return v
+	case "row":
+		key, _ := w.rowKey(row)
+		return key
+	}
+	return ""
+}
+
+func (w *ChangeWriter) rowKey(row map[string]any) (string, bool) {
+	return w.tmpl.Render(func(field string) (string, bool) {
+		return columnKey(w.enc, w.cfg, row, field)
+	})
+}
@@ -0,0 +1,172 @@
+package loader
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"red-courier/internal/config"
+)
+
+func cdcTask(structure string) config.TaskConfig {
+	return config.TaskConfig{
+		Name:      "orders_cdc",
+		Table:     "public.orders",
+		Alias:     "orders",
+		Structure: structure,
+		CDC: &config.CDCConfig{
+			Slot:        "red_courier_orders",
+			Publication: "red_courier",
+			LSNKey:      "lsn:orders_cdc",
+		},
+	}
+}
+
+func TestChangeWriter_Map(t *testing.T) {
+	mr, r := newTestRedis(t)
+	ctx := context.Background()
+
+	cfg := cdcTask("map")
+	cfg.Key, cfg.Value = "id", "status"
+	w, err := NewChangeWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewChangeWriter: %v", err)
+	}
+
+	if err := w.Apply(ctx, r, []Change{
+		{Op: OpInsert, Row: map[string]any{"id": int64(1), "status": "NEW"}},
+		{Op: OpInsert, Row: map[string]any{"id": int64(2), "status": "NEW"}},
+		{Op: OpUpdate, Row: map[string]any{"id": int64(1), "status": "PAID"}},
+		{Op: OpUpdate, Row: map[string]any{"id": int64(3), "status": "NEW"}, Old: map[string]any{"id": int64(2)}},
+	}, "0/16B3748"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := mr.HGet("orders", "1"); got != "PAID" {
+		t.Errorf("orders[1]: got %q want PAID", got)
+	}
+	if mr.HGet("orders", "2") != "" || mr.HGet("orders", "3") != "NEW" {
+		keys, _ := mr.HKeys("orders")
+		t.Errorf("update of the key should move the field, got fields %v", keys)
+	}
+	if got, _ := mr.Get("lsn:orders_cdc"); got != "0/16B3748" {
+		t.Errorf("lsn: got %q want 0/16B3748", got)
+	}
+
+	// A delete only carries the replica identity.
+	if err := w.Apply(ctx, r, []Change{{Op: OpDelete, Row: map[string]any{"id": int64(1)}}}, "0/16B3800"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if keys, _ := mr.HKeys("orders"); len(keys) != 1 || keys[0] != "3" {
+		t.Errorf("after delete: got fields %v want [3]", keys)
+	}
+}
+
+func TestChangeWriter_SetNeedsValueToDelete(t *testing.T) {
+	mr, r := newTestRedis(t)
+	ctx := context.Background()
+
+	cfg := cdcTask("set")
+	cfg.Value = "sku"
+	w, err := NewChangeWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewChangeWriter: %v", err)
+	}
+
+	if err := w.Apply(ctx, r, []Change{
+		{Op: OpInsert, Row: map[string]any{"id": int64(1), "sku": "A"}},
+		{Op: OpInsert, Row: map[string]any{"id": int64(2), "sku": "B"}},
+		{Op: OpDelete, Row: map[string]any{"id": int64(1), "sku": "A"}}, // REPLICA IDENTITY FULL
+		{Op: OpDelete, Row: map[string]any{"id": int64(2)}},             // default identity: no value
+	}, ""); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	members, _ := mr.Members("orders")
+	if len(members) != 1 || members[0] != "B" {
+		t.Errorf("got members %v want [B]", members)
+	}
+	if mr.Exists("lsn:orders_cdc") {
+		t.Errorf("an empty lsn must not be stored")
+	}
+}
+
+func TestChangeWriter_StreamRecordsOp(t *testing.T) {
+	mr, r := newTestRedis(t)
+	ctx := context.Background()
+
+	cfg := cdcTask("stream")
+	cfg.Fields = []string{"id", "status"}
+	w, err := NewChangeWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewChangeWriter: %v", err)
+	}
+
+	if err := w.Apply(ctx, r, []Change{
+		{Op: OpInsert, Row: map[string]any{"id": int64(1), "status": "NEW"}},
+		{Op: OpDelete, Row: map[string]any{"id": int64(1)}},
+		{Op: OpTruncate},
+	}, "0/1"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	entries, err := mr.Stream("orders")
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	want := [][]string{{"id", "1", "op", "insert", "status", "NEW"}, {"id", "1", "op", "delete"}, {"op", "truncate"}}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries want %d", len(entries), len(want))
+	}
+	for i, e := range entries {
+		got := sortedPairs(e.Values)
+		if len(got) != len(want[i]) {
+			t.Errorf("entry %d: got %v want %v", i, e.Values, want[i])
+			continue
+		}
+		for j := range got {
+			if got[j] != want[i][j] {
+				t.Errorf("entry %d: got %v want %v", i, e.Values, want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestChangeWriter_RowKeyChange(t *testing.T) {
+	mr, r := newTestRedis(t)
+	ctx := context.Background()
+
+	cfg := cdcTask("row")
+	cfg.KeyTemplate = "order_status:{id}"
+	cfg.Value = "status"
+	w, err := NewChangeWriter(cfg)
+	if err != nil {
+		t.Fatalf("NewChangeWriter: %v", err)
+	}
+
+	if err := w.Apply(ctx, r, []Change{
+		{Op: OpInsert, Row: map[string]any{"id": int64(7), "status": "NEW"}},
+		{Op: OpUpdate, Row: map[string]any{"id": int64(8), "status": "NEW"}, Old: map[string]any{"id": int64(7)}},
+		{Op: OpTruncate}, // per-row keys are left alone
+	}, "0/2"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if mr.Exists("order_status:7") {
+		t.Errorf("old key should be deleted")
+	}
+	if got, _ := mr.Get("order_status:8"); got != "NEW" {
+		t.Errorf("order_status:8: got %q want NEW", got)
+	}
+}
+
+// sortedPairs flattens stream entry values (field, value, ...) sorted by field.
+func sortedPairs(values []string) []string {
+	var pairs [][2]string
+	for i := 0; i+1 < len(values); i += 2 {
+		pairs = append(pairs, [2]string{values[i], values[i+1]})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	var out []string
+	for _, p := range pairs {
+		out = append(out, p[0], p[1])
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+package loader
+
+import (
+	"fmt"
+	"strings"
+
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
+)
+
+// checkpoint follows the tracking columns across batches and is written to
+// Redis in the same script as each batch's data (see writeBatches).
+type checkpoint struct {
+	key        string
+	columns    []string
+	descending bool // "<" and "<=" walk the cursor downwards
+	value      []any
+}
+
+// newCheckpoint returns the checkpoint for a tracked task, or nil.
+func newCheckpoint(cfg config.TaskConfig) *checkpoint {
+	if cfg.Tracking == nil {
+		return nil
+	}
+	return &checkpoint{
+		key:        cfg.Tracking.LastValueKey,
+		columns:    cfg.TrackingColumns(),
+		descending: strings.HasPrefix(cfg.Tracking.Operator, "<"),
+	}
+}
+
+// observe advances the checkpoint past every row in batch. It fails when a row's
+// tracking values cannot be compared with the checkpoint's, rather than guess.
+func (c *checkpoint) observe(batch []map[string]any) error {
+	for _, row := range batch {
+		v := c.cursorOf(row)
+		if v == nil {
+			continue
+		}
+		if c.value == nil {
+			c.value = v
+			continue
+		}
+		cmp, err := pgvalue.CompareTuple(v, c.value)
+		if err != nil {
+			return fmt.Errorf("checkpoint %s: %w", c.key, err)
+		}
+		if (cmp > 0 && !c.descending) || (cmp < 0 && c.descending) {
+			c.value = v
+		}
+	}
+	return nil
+}
+
+// sameCursor reports whether two rows share a tracking cursor, in which case
+// they must be committed in the same batch.
+func (c *checkpoint) sameCursor(a, b map[string]any) bool {
+	va, vb := c.cursorOf(a), c.cursorOf(b)
+	if va == nil || vb == nil {
+		return false
+	}
+	cmp, err := pgvalue.CompareTuple(va, vb)
+	return err == nil && cmp == 0
+}
+
+// cursorOf returns the row's tracking values, or nil if any of them is NULL.
+func (c *checkpoint) cursorOf(row map[string]any) []any {
+	vals := make([]any, len(c.columns))
+	for i, col := range c.columns {
+		if row[col] == nil {
+			return nil
+		}
+		vals[i] = row[col]
+	}
+	return vals
+}
+
+// encoded returns the value stored at the checkpoint key. Tracking cursors keep the
+// type of each value so they bind as typed parameters when read back; a checkpoint
+// without tracking columns (a CDC position) is stored as its plain string.
+func (c *checkpoint) encoded() (string, error) {
+	if len(c.columns) == 0 && len(c.value) == 1 {
+		return pgvalue.Default.String(c.value[0]), nil
+	}
+	s, err := pgvalue.EncodeCursor(c.value)
+	if err != nil {
+		return "", fmt.Errorf("checkpoint %s: %w", c.key, err)
+	}
+	return s, nil
+}
+
+// commitScript applies a batch of commands and then stores the checkpoint.
+// ARGV[1] is the fencing token the write is made under, or empty when it is not
+// fenced, and ARGV[2] the checkpoint value, or empty when there is none. A fenced
+// write first checks the token against the latest one at KEYS[1] and returns -1
+// without writing anything if they differ. The checkpoint key is the next key,
+// followed by every key the commands write; they are declared but not read by
+// position. The rest of ARGV holds, for each command, its argument count, the
+// explicit entry ID of an XADD (or empty) and its arguments. A failing command
+// stops the script before the checkpoint is written and its 1-based index is
+// returned with the error, so the rows are re-sent on the next run instead of
+// being skipped. An XADD whose ID the stream has passed is a replay, and not a
+// failure, when the stream holds that entry or has trimmed below it; the number of
+// replays is returned third. Otherwise the row arrived out of ID order and fails.
+var commitScript = goredis.NewScript(`
+local function below(a, b)
+  local am, as = string.match(a, '^(%d+)-(%d+)$')
+  local bm, bs = string.match(b, '^(%d+)-(%d+)$')
+  if tonumber(am) ~= tonumber(bm) then
+    return tonumber(am) < tonumber(bm)
+  end
+  return tonumber(as) < tonumber(bs)
+end
+local k = 1
+if ARGV[1] ~= '' then
+  if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+    return {-1, 'fenced'}
+  end
+  k = 2
+end
+local i = 3
+local c = 0
+local replayed = 0
+while i <= #ARGV do
+  local argc = tonumber(ARGV[i])
+  local id = ARGV[i + 1]
+  c = c + 1
+  local res = redis.pcall(unpack(ARGV, i + 2, i + 1 + argc))
+  if type(res) == 'table' and res.err then
+    if id == '' or not string.find(res.err, '` + replayedEntryErr + `', 1, true) then
+      return {c, res.err}
+    end
+    local stream = ARGV[i + 3]
+    local first = redis.call('XRANGE', stream, '-', '+', 'COUNT', 1)
+    if #redis.call('XRANGE', stream, id, id) == 0 and first[1] and not below(id, first[1][1]) then
+      return {c, 'entry ' .. id .. ' was never written but is below the top of the stream: rows must arrive in entry ID order'}
+    end
+    replayed = replayed + 1
+  end
+  i = i + argc + 2
+end
+if ARGV[2] ~= '' then
+  redis.call('SET', KEYS[k], ARGV[2])
+end
+return {0, '', replayed}
+`)
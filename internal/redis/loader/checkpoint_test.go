@@ -0,0 +1,262 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/config"
+	"red-courier/internal/leader"
+	"red-courier/internal/rowstream"
+)
+
+func trackedStream(batchSize int) config.TaskConfig {
+	return config.TaskConfig{
+		Name:      "orders_stream",
+		Alias:     "orders",
+		Structure: "stream",
+		Fields:    []string{"id", "updated_at"},
+		BatchSize: batchSize,
+		Tracking: &config.TrackingConfig{
+			Column:       "updated_at",
+			Operator:     ">",
+			LastValueKey: "checkpoint:orders",
+		},
+	}
+}
+
+func TestCheckpoint_CommittedWithData(t *testing.T) {
+	mr, r := newTestRedis(t)
+	cfg := trackedStream(2)
+
+	base := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	var rows []map[string]any
+	for i := 0; i < 5; i++ {
+		rows = append(rows, map[string]any{"id": int64(i), "updated_at": base.Add(time.Duration(i) * time.Second)})
+	}
+
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got, _ := mr.Get("checkpoint:orders")
+	if want := `[["timestamptz","` + base.Add(4*time.Second).Format(time.RFC3339Nano) + `"]]`; got != want {
+		t.Errorf("checkpoint: got %q want %q", got, want)
+	}
+	if entries, _ := mr.Stream("orders"); len(entries) != 5 {
+		t.Errorf("stream: got %d entries want 5", len(entries))
+	}
+}
+
+func TestCheckpoint_NotAdvancedPastFailedBatch(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	cfg := config.TaskConfig{
+		Name:        "orders_by_id",
+		Structure:   "row",
+		KeyTemplate: "order:{id}",
+		Fields:      []string{"id", "version"},
+		BatchSize:   2,
+		Tracking: &config.TrackingConfig{
+			Column:       "version",
+			Operator:     ">",
+			LastValueKey: "checkpoint:orders_by_id",
+		},
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	// order:3 holds a string, so the second batch (rows 2-3) fails.
+	if err := mr.Set("order:3", "not a hash"); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	var rows []map[string]any
+	for i := 0; i < 6; i++ {
+		rows = append(rows, map[string]any{"id": int64(i), "version": int64(10 + i)})
+	}
+
+	err = ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r)
+	var be *BatchError
+	if !errors.As(err, &be) || be.FirstRow != 3 {
+		t.Fatalf("expected batch error at row 3, got %v", err)
+	}
+	if got, _ := mr.Get("checkpoint:orders_by_id"); got != `[["int8","11"]]` {
+		t.Errorf("checkpoint should stay at the last committed batch: got %q want %q", got, `[["int8","11"]]`)
+	}
+}
+
+func TestCheckpoint_EqualValuesNotSplitByCheckpoint(t *testing.T) {
+	mr, r := newTestRedis(t)
+	cfg := trackedStream(2)
+
+	ts := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	rows := []map[string]any{
+		{"id": int64(1), "updated_at": ts},
+		{"id": int64(2), "updated_at": ts.Add(time.Second)},
+		{"id": int64(3), "updated_at": ts.Add(time.Second)},
+		{"id": int64(4), "updated_at": ts.Add(time.Second)},
+		{"id": int64(5), "updated_at": ts.Add(2 * time.Second)},
+	}
+
+	// Batches keep batch_size; the checkpoint seen by each batch is the one the
+	// previous batches committed.
+	cp := newCheckpoint(cfg)
+	var batches [][2]int
+	var seen []string
+	err := writeBatches(context.Background(), r, "orders", rowstream.FromSlice(rows), 2, cp, func(batch []map[string]any, offset int) ([]queuedCmd, error) {
+		batches = append(batches, [2]int{offset, offset + len(batch) - 1})
+		v, _ := mr.Get("checkpoint:orders")
+		seen = append(seen, v)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("writeBatches: %v", err)
+	}
+
+	want := [][2]int{{0, 1}, {2, 3}, {4, 4}}
+	if len(batches) != len(want) || batches[0] != want[0] || batches[1] != want[1] || batches[2] != want[2] {
+		t.Errorf("batches: got %v want %v", batches, want)
+	}
+	at := func(d time.Duration) string {
+		return `[["timestamptz","` + ts.Add(d).Format(time.RFC3339Nano) + `"]]`
+	}
+	// Rows 1-3 share a cursor, so the first batch moves no checkpoint.
+	if wantSeen := []string{"", "", at(time.Second)}; len(seen) != 3 || seen[0] != wantSeen[0] || seen[1] != wantSeen[1] || seen[2] != wantSeen[2] {
+		t.Errorf("checkpoints: got %q want %q", seen, wantSeen)
+	}
+	if got, _ := mr.Get("checkpoint:orders"); got != at(2*time.Second) {
+		t.Errorf("final checkpoint: got %q", got)
+	}
+}
+
+func TestCommandKeys(t *testing.T) {
+	for _, tc := range []struct {
+		args []any
+		want []string
+	}{
+		{[]any{"hset", "orders", "1", "a"}, []string{"orders"}},
+		{[]any{"del", "a", "b"}, []string{"a", "b"}},
+		{[]any{"rename", "tmp", "orders"}, []string{"tmp", "orders"}},
+		{[]any{"ping"}, nil},
+	} {
+		if got := commandKeys(tc.args); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("commandKeys(%v): got %v want %v", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestCheckpoint_CompositeCursor(t *testing.T) {
+	mr, r := newTestRedis(t)
+	cfg := trackedStream(2)
+	cfg.Tracking.Column = ""
+	cfg.Tracking.Columns = []string{"updated_at", "id"}
+
+	base := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	rows := []map[string]any{
+		{"id": int64(1), "updated_at": base},
+		{"id": int64(7), "updated_at": base.Add(time.Second)},
+		{"id": int64(3), "updated_at": base.Add(time.Second)},
+	}
+
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got, _ := mr.Get("checkpoint:orders")
+	if want := `[["timestamptz","` + base.Add(time.Second).Format(time.RFC3339Nano) + `"],["int8","7"]]`; got != want {
+		t.Errorf("checkpoint: got %q want %q", got, want)
+	}
+}
+
+func TestCheckpoint_FencedCommit(t *testing.T) {
+	mr, r := newTestRedis(t)
+	cfg := trackedStream(10)
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	rows := []map[string]any{{"id": int64(1), "updated_at": time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)}}
+
+	// Another replica has since been elected with token 8.
+	mr.Set("leader:token", "8")
+	stale := leader.WithFence(context.Background(), leader.Fence{Key: "leader:token", Token: 7})
+	err = ld.Load(stale, rowstream.FromSlice(rows), cfg, r)
+	if !errors.Is(err, leader.ErrFenced) {
+		t.Fatalf("expected ErrFenced, got %v", err)
+	}
+	if mr.Exists("orders") || mr.Exists("checkpoint:orders") {
+		t.Errorf("a fenced write must not touch Redis")
+	}
+
+	current := leader.WithFence(context.Background(), leader.Fence{Key: "leader:token", Token: 8})
+	if err := ld.Load(current, rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !mr.Exists("checkpoint:orders") {
+		t.Errorf("the current leader's write should be committed")
+	}
+}
+
+func TestFence_EveryWritePath(t *testing.T) {
+	mr, r := newTestRedis(t)
+	mr.Set("leader:token", "8")
+	stale := leader.WithFence(context.Background(), leader.Fence{Key: "leader:token", Token: 7})
+	rows := []map[string]any{{"id": int64(1), "name": "Ada"}}
+
+	for _, cfg := range []config.TaskConfig{
+		{Name: "untracked", Alias: "untracked", Structure: "stream", Fields: []string{"id"}},
+		{Name: "snap", Alias: "snap", Structure: "snapshot", Key: "id", Value: "name", Snapshot: &config.SnapshotConfig{Structure: "map"}},
+		{Name: "repl", Alias: "repl", Structure: "map", Key: "id", Value: "name", Mode: "replace", MaxDeleteRatio: new(float64)},
+	} {
+		ld, err := NewLoader(cfg)
+		if err != nil {
+			t.Fatalf("%s: NewLoader: %v", cfg.Name, err)
+		}
+		if err := ld.Load(stale, rowstream.FromSlice(rows), cfg, r); !errors.Is(err, leader.ErrFenced) {
+			t.Errorf("%s: expected ErrFenced, got %v", cfg.Name, err)
+		}
+	}
+	if keys := mr.Keys(); len(keys) != 1 {
+		t.Errorf("fenced loads must not write, found keys %v", keys)
+	}
+
+	// Snapshot RENAME and replace-mode deletes are fenced too.
+	mr.HSet("snap:snapshot:tmp", "1", "Ada")
+	if err := write(stale, r, "snap", goredis.NewStatusCmd(stale, "rename", "snap:snapshot:tmp", "snap")); !errors.Is(err, leader.ErrFenced) {
+		t.Errorf("rename: expected ErrFenced, got %v", err)
+	}
+	mr.HSet("repl", "2", "Grace")
+	if err := removeMembers(stale, "map", "repl", []string{"2"}, r); !errors.Is(err, leader.ErrFenced) {
+		t.Errorf("remove: expected ErrFenced, got %v", err)
+	}
+	if mr.Exists("snap") || mr.HGet("repl", "2") != "Grace" {
+		t.Errorf("fenced rename or delete was applied")
+	}
+
+	// So is a CDC flush in the middle of a transaction, which stores no LSN.
+	cdc := cdcTask("map")
+	cdc.Key, cdc.Value = "id", "name"
+	w, err := NewChangeWriter(cdc)
+	if err != nil {
+		t.Fatalf("NewChangeWriter: %v", err)
+	}
+	if err := w.Apply(stale, r, []Change{{Op: OpInsert, Row: rows[0]}}, ""); !errors.Is(err, leader.ErrFenced) {
+		t.Errorf("cdc flush: expected ErrFenced, got %v", err)
+	}
+	if mr.Exists("orders") {
+		t.Errorf("fenced cdc flush was applied")
+	}
+}
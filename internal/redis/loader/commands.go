@@ -0,0 +1,59 @@
+package loader
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Loaders build their writes as standalone commands, which commit then pipelines
+// or passes to its script. These constructors cover the commands whose arguments
+// go-redis would otherwise only flatten on a client or pipeline.
+
+// hsetCmd sets fields on the hash at key, in field order.
+func hsetCmd(ctx context.Context, key string, fields map[string]any) *goredis.IntCmd {
+	args := make([]any, 0, 2+2*len(fields))
+	args = append(args, "hset", key)
+	for _, f := range slices.Sorted(maps.Keys(fields)) {
+		args = append(args, f, fields[f])
+	}
+	return goredis.NewIntCmd(ctx, args...)
+}
+
+// zaddCmd adds members to the sorted set at key.
+func zaddCmd(ctx context.Context, key string, members ...goredis.Z) *goredis.IntCmd {
+	args := make([]any, 0, 2+2*len(members))
+	args = append(args, "zadd", key)
+	for _, m := range members {
+		args = append(args, m.Score, m.Member)
+	}
+	return goredis.NewIntCmd(ctx, args...)
+}
+
+// xaddCmd appends an entry as described by a, honouring the trimming and entry
+// ID options the loaders set (see trimStream).
+func xaddCmd(ctx context.Context, a *goredis.XAddArgs) *goredis.StringCmd {
+	args := []any{"xadd", a.Stream}
+	switch {
+	case a.MaxLen > 0 && a.Approx:
+		args = append(args, "maxlen", "~", a.MaxLen)
+	case a.MaxLen > 0:
+		args = append(args, "maxlen", a.MaxLen)
+	case a.MinID != "" && a.Approx:
+		args = append(args, "minid", "~", a.MinID)
+	case a.MinID != "":
+		args = append(args, "minid", a.MinID)
+	}
+	if a.ID != "" {
+		args = append(args, a.ID)
+	} else {
+		args = append(args, "*")
+	}
+	values, _ := a.Values.(map[string]any)
+	for _, f := range slices.Sorted(maps.Keys(values)) {
+		args = append(args, f, values[f])
+	}
+	return goredis.NewStringCmd(ctx, args...)
+}
@@ -2,25 +2,34 @@ package loader
 
 import (
 	"context"
-	"fmt"
+	goredis "github.com/redis/go-redis/v9"
 	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
 	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
 )
 
 type ListLoader struct {
 	ValueField string
+	BatchSize  int
+	Encoder    *pgvalue.Encoder
 }
 
-func (l *ListLoader) Load(ctx context.Context, rows []map[string]any, cfg config.TaskConfig, r *redis.RedisClient) error {
+func (l *ListLoader) Load(ctx context.Context, rows *rowstream.Stream, cfg config.TaskConfig, r *redis.RedisClient) error {
 	key := cfg.EffectiveRedisKey()
-	for _, row := range rows {
-		val, ok := row[cfg.ResolveColumn(cfg.Value)]
-		if !ok {
-			continue
+	return writeBatches(ctx, r, key, rows, l.BatchSize, newCheckpoint(cfg), func(batch []map[string]any, offset int) ([]queuedCmd, error) {
+		vals := make([]any, 0, len(batch))
+		for _, row := range batch {
+			val, ok := columnValue(l.Encoder, cfg, row, cfg.Value)
+			if !ok {
+				continue
+			}
+			vals = append(vals, val)
 		}
-		if err := r.Client.LPush(ctx, key, val).Err(); err != nil {
-			return fmt.Errorf("failed to LPUSH to Redis list: %w", err)
+		if len(vals) == 0 {
+			return nil, nil
 		}
-	}
-	return nil
+		// A variadic LPUSH inserts left to right, matching one LPUSH per row.
+		return []queuedCmd{{cmd: goredis.NewIntCmd(ctx, append([]any{"lpush", key}, vals...)...), firstRow: offset, lastRow: offset + len(batch) - 1}}, nil
+	})
 }
@@ -5,42 +5,99 @@ import (
 	"fmt"
 
 	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
 	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
 )
 
 type Loader interface {
-	Load(ctx context.Context, rows []map[string]any, cfg config.TaskConfig, r *redis.RedisClient) error
+	Load(ctx context.Context, rows *rowstream.Stream, cfg config.TaskConfig, r *redis.RedisClient) error
 }
 
 func NewLoader(cfg config.TaskConfig) (Loader, error) {
+	enc, err := cfg.Encoder()
+	if err != nil {
+		return nil, err
+	}
+	ld, err := newStructureLoader(cfg, enc)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Mode == "replace" {
+		return &ReplaceLoader{
+			Inner:          ld,
+			MaxDeleteRatio: cfg.EffectiveMaxDeleteRatio(),
+			Encoder:        enc,
+		}, nil
+	}
+	return ld, nil
+}
+
+func newStructureLoader(cfg config.TaskConfig, enc *pgvalue.Encoder) (Loader, error) {
 	switch cfg.Structure {
 	case "map":
 		return &MapLoader{
 			KeyField:   cfg.Key,
 			ValueField: cfg.Value,
+			BatchSize:  cfg.EffectiveBatchSize(),
+			Encoder:    enc,
 		}, nil
 
 	case "list":
 		return &ListLoader{
 			ValueField: cfg.Value,
+			BatchSize:  cfg.EffectiveBatchSize(),
+			Encoder:    enc,
 		}, nil
 
 	case "set":
 		return &SetLoader{
 			ValueField: cfg.Value,
+			BatchSize:  cfg.EffectiveBatchSize(),
+			Encoder:    enc,
 		}, nil
 
 	case "sorted_set":
 		return &SortedSetLoader{
 			ValueField: cfg.Value,
 			ScoreField: cfg.Score,
+			BatchSize:  cfg.EffectiveBatchSize(),
+			Encoder:    enc,
 		}, nil
 
 	case "stream":
 		return &StreamLoader{
-			Fields: cfg.Fields,
+			Fields:    cfg.Fields,
+			BatchSize: cfg.EffectiveBatchSize(),
+			Encoder:   enc,
+			Options:   cfg.Stream,
 		}, nil
 
+	case "row":
+		tmpl, err := config.ParseKeyTemplate(cfg.EffectiveKeyTemplate())
+		if err != nil {
+			return nil, err
+		}
+		return &RowLoader{
+			Template:   tmpl,
+			Fields:     cfg.Fields,
+			ValueField: cfg.Value,
+			BatchSize:  cfg.EffectiveBatchSize(),
+			Encoder:    enc,
+		}, nil
+
+	case "snapshot":
+		innerCfg := cfg
+		innerCfg.Structure = cfg.DataStructure()
+		if innerCfg.Structure == "snapshot" {
+			return nil, fmt.Errorf("unsupported snapshot structure: %s", innerCfg.Structure)
+		}
+		inner, err := NewLoader(innerCfg)
+		if err != nil {
+			return nil, err
+		}
+		return &SnapshotLoader{Inner: inner}, nil
+
 	default:
 		return nil, fmt.Errorf("unsupported Redis structure: %s", cfg.Structure)
 	}
@@ -2,27 +2,35 @@ package loader
 
 import (
 	"context"
-	"fmt"
+	goredis "github.com/redis/go-redis/v9"
 	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
 	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
 )
 
 type MapLoader struct {
 	KeyField   string
 	ValueField string
+	BatchSize  int
+	Encoder    *pgvalue.Encoder
 }
 
-func (l *MapLoader) Load(ctx context.Context, rows []map[string]any, cfg config.TaskConfig, r *redis.RedisClient) error {
+func (l *MapLoader) Load(ctx context.Context, rows *rowstream.Stream, cfg config.TaskConfig, r *redis.RedisClient) error {
 	key := cfg.EffectiveRedisKey()
-	for _, row := range rows {
-		k, kOk := row[cfg.ResolveColumn(cfg.Key)]
-		v, vOk := row[cfg.ResolveColumn(cfg.Value)]
-		if !kOk || !vOk {
-			continue
+	return writeBatches(ctx, r, key, rows, l.BatchSize, newCheckpoint(cfg), func(batch []map[string]any, offset int) ([]queuedCmd, error) {
+		pairs := make([]any, 0, 2*len(batch))
+		for _, row := range batch {
+			k, kOk := columnKey(l.Encoder, cfg, row, cfg.Key)
+			v, vOk := columnValue(l.Encoder, cfg, row, cfg.Value)
+			if !kOk || !vOk {
+				continue
+			}
+			pairs = append(pairs, k, v)
 		}
-		if err := r.Client.HSet(ctx, key, k, v).Err(); err != nil {
-			return fmt.Errorf("failed to HSET to Redis map: %w", err)
+		if len(pairs) == 0 {
+			return nil, nil
 		}
-	}
-	return nil
+		return []queuedCmd{{cmd: goredis.NewIntCmd(ctx, append([]any{"hset", key}, pairs...)...), firstRow: offset, lastRow: offset + len(batch) - 1}}, nil
+	})
 }
@@ -0,0 +1,216 @@
+package loader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
+	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
+)
+
+// deleteBatchSize bounds the number of members passed to a single HDEL/SREM/ZREM,
+// SADD into the member set or SMISMEMBER, and the COUNT hint of each scan.
+const deleteBatchSize = 500
+
+// tempKeyTTL expires the temporary keys of a run that died before removing them.
+const tempKeyTTL = 24 * time.Hour
+
+// ReplaceLoader implements mode "replace" for map, set and sorted_set tasks:
+// after the inner loader has written the current rows, members that are no
+// longer returned by the query are removed. The current members are streamed
+// into a temporary Redis set as the rows pass through, and the stale ones are
+// found by scanning the live key against it, so memory stays bounded by the
+// batch size. It refuses to delete when the share of members to remove exceeds
+// MaxDeleteRatio, which guards against a bad WHERE clause or an accidentally
+// truncated table emptying the cache; the current rows are written either way.
+type ReplaceLoader struct {
+	Inner          Loader
+	MaxDeleteRatio float64
+	Encoder        *pgvalue.Encoder // must match the inner loader's, so members compare equal
+}
+
+func (l *ReplaceLoader) Load(ctx context.Context, rows *rowstream.Stream, cfg config.TaskConfig, r *redis.RedisClient) error {
+	key := cfg.EffectiveRedisKey()
+	if _, ok := removeCommands[cfg.Structure]; !ok {
+		return fmt.Errorf("mode replace is not supported for structure: %s", cfg.Structure)
+	}
+
+	existing, err := memberCount(ctx, cfg.Structure, key, r)
+	if err != nil {
+		return err
+	}
+
+	seen := tempKey(key, "replace")
+	defer func() {
+		// The member set is ours alone; drop it even when ctx is done.
+		_ = r.Client.Del(context.WithoutCancel(ctx), seen).Err()
+	}()
+
+	tapped := rowstream.New(ctx, cfg.EffectiveBatchSize(), func(ctx context.Context, emit func(map[string]any) error) error {
+		members := make([]string, 0, deleteBatchSize)
+		flush := func() error {
+			if len(members) == 0 {
+				return nil
+			}
+			args := []any{"sadd", seen}
+			for _, m := range members {
+				args = append(args, m)
+			}
+			members = members[:0]
+			if err := write(ctx, r, seen, goredis.NewIntCmd(ctx, args...),
+				goredis.NewBoolCmd(ctx, "pexpire", seen, tempKeyTTL.Milliseconds())); err != nil {
+				return fmt.Errorf("failed to record current members of %s: %w", key, err)
+			}
+			return nil
+		}
+		for {
+			row, ok := rows.Next()
+			if !ok {
+				break
+			}
+			if m, ok := l.member(cfg, row); ok {
+				members = append(members, m)
+				if len(members) == deleteBatchSize {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+			if err := emit(row); err != nil {
+				return err
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return flush()
+	})
+	defer tapped.Close()
+
+	if err := l.Inner.Load(ctx, tapped, cfg, r); err != nil {
+		return err
+	}
+
+	stale := 0
+	if err := scanStale(ctx, cfg.Structure, key, seen, r, func(members []string) error {
+		stale += len(members)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if stale == 0 {
+		return nil
+	}
+	if ratio := float64(stale) / float64(max(existing, stale)); ratio > l.MaxDeleteRatio {
+		return fmt.Errorf("replace aborted: would delete %d of %d members from %s (%.0f%% > max_delete_ratio %.0f%%)",
+			stale, max(existing, stale), key, ratio*100, l.MaxDeleteRatio*100)
+	}
+
+	return scanStale(ctx, cfg.Structure, key, seen, r, func(members []string) error {
+		return removeMembers(ctx, cfg.Structure, key, members, r)
+	})
+}
+
+// member returns the hash field or member a row writes, as the inner loader encodes it.
+func (l *ReplaceLoader) member(cfg config.TaskConfig, row map[string]any) (string, bool) {
+	if cfg.Structure == "map" {
+		return columnKey(l.Encoder, cfg, row, cfg.Key)
+	}
+	return columnValue(l.Encoder, cfg, row, cfg.Value)
+}
+
+// tempKey returns a key next to key that no other run uses, for state a single
+// run builds up and removes again.
+func tempKey(key, purpose string) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return key + ":" + purpose + ":" + hex.EncodeToString(b)
+}
+
+func memberCount(ctx context.Context, structure, key string, r *redis.RedisClient) (int, error) {
+	var cmd *goredis.IntCmd
+	switch structure {
+	case "map":
+		cmd = r.Client.HLen(ctx, key)
+	case "set":
+		cmd = r.Client.SCard(ctx, key)
+	default:
+		cmd = r.Client.ZCard(ctx, key)
+	}
+	n, err := cmd.Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count existing members of %s: %w", key, err)
+	}
+	return int(n), nil
+}
+
+// scanStale scans key and passes each page's members that are not in the set
+// seen to fn. A member may be reported twice if key changes during the scan.
+func scanStale(ctx context.Context, structure, key, seen string, r *redis.RedisClient, fn func(members []string) error) error {
+	var cursor uint64
+	for {
+		var (
+			page []string
+			err  error
+		)
+		// HSCAN and ZSCAN return field/score pairs, SSCAN bare members.
+		stride := 2
+		switch structure {
+		case "map":
+			page, cursor, err = r.Client.HScan(ctx, key, cursor, "", deleteBatchSize).Result()
+		case "set":
+			page, cursor, err = r.Client.SScan(ctx, key, cursor, "", deleteBatchSize).Result()
+			stride = 1
+		default:
+			page, cursor, err = r.Client.ZScan(ctx, key, cursor, "", deleteBatchSize).Result()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to scan existing members of %s: %w", key, err)
+		}
+
+		members := make([]any, 0, len(page)/stride)
+		for i := 0; i < len(page); i += stride {
+			members = append(members, page[i])
+		}
+		if len(members) > 0 {
+			current, err := r.Client.SMIsMember(ctx, seen, members...).Result()
+			if err != nil {
+				return fmt.Errorf("failed to compare members of %s: %w", key, err)
+			}
+			var stale []string
+			for i, ok := range current {
+				if !ok {
+					stale = append(stale, members[i].(string))
+				}
+			}
+			if len(stale) > 0 {
+				if err := fn(stale); err != nil {
+					return err
+				}
+			}
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func removeMembers(ctx context.Context, structure, key string, members []string, r *redis.RedisClient) error {
+	args := []any{removeCommands[structure], key}
+	for _, m := range members {
+		args = append(args, m)
+	}
+	if err := write(ctx, r, key, goredis.NewIntCmd(ctx, args...)); err != nil {
+		return fmt.Errorf("failed to remove stale members from %s: %w", key, err)
+	}
+	return nil
+}
+
+// removeCommands removes members of the structures mode "replace" supports.
+var removeCommands = map[string]string{"map": "hdel", "set": "srem", "sorted_set": "zrem"}
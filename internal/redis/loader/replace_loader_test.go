@@ -0,0 +1,128 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"red-courier/internal/config"
+	"red-courier/internal/rowstream"
+)
+
+func TestReplaceLoader_RemovesDeletedRows(t *testing.T) {
+	mr, r := newTestRedis(t)
+	ctx := context.Background()
+
+	cfg := config.TaskConfig{
+		Name:      "customer_map",
+		Alias:     "customer_map",
+		Structure: "map",
+		Key:       "id",
+		Value:     "name",
+		Mode:      "replace",
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		mr.HSet("customer_map", id, "old")
+	}
+
+	rows := []map[string]any{
+		{"id": int64(1), "name": "Ada"},
+		{"id": int64(2), "name": "Grace"},
+	}
+	if err := ld.Load(ctx, rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if mr.HGet("customer_map", "3") != "" {
+		t.Errorf("customer 3 should have been removed")
+	}
+	if got := mr.HGet("customer_map", "1"); got != "Ada" {
+		t.Errorf("customer 1: got %q want %q", got, "Ada")
+	}
+}
+
+func TestReplaceLoader_AbortsAboveDeleteRatio(t *testing.T) {
+	mr, r := newTestRedis(t)
+	ctx := context.Background()
+
+	ratio := 0.25
+	cfg := config.TaskConfig{
+		Name:           "active_users",
+		Alias:          "active_users",
+		Structure:      "set",
+		Value:          "user_id",
+		Mode:           "replace",
+		MaxDeleteRatio: &ratio,
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	if _, err := mr.SetAdd("active_users", "a", "b", "c", "d"); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	rows := []map[string]any{{"user_id": "a"}, {"user_id": "e"}}
+	err = ld.Load(ctx, rowstream.FromSlice(rows), cfg, r)
+	if err == nil || !strings.Contains(err.Error(), "replace aborted") {
+		t.Fatalf("expected replace to abort, got %v", err)
+	}
+
+	// The current rows are written, but nothing is deleted.
+	members, _ := mr.Members("active_users")
+	if strings.Join(members, ",") != "a,b,c,d,e" {
+		t.Errorf("aborted replace must not delete members, got %v", members)
+	}
+	if keys := mr.Keys(); len(keys) != 1 {
+		t.Errorf("the member set should be removed, keys %v", keys)
+	}
+}
+
+func TestReplaceLoader_StreamsMoreThanOneBatch(t *testing.T) {
+	mr, r := newTestRedis(t)
+	ctx := context.Background()
+
+	cfg := config.TaskConfig{
+		Name:      "scores",
+		Alias:     "scores",
+		Structure: "sorted_set",
+		Value:     "id",
+		Score:     "score",
+		Mode:      "replace",
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	n := 2*deleteBatchSize + 10
+	rows := make([]map[string]any, 0, n)
+	for i := range n {
+		mr.ZAdd("scores", 0, fmt.Sprintf("old-%d", i%5))
+		rows = append(rows, map[string]any{"id": fmt.Sprintf("m-%d", i), "score": float64(i)})
+		mr.ZAdd("scores", 0, fmt.Sprintf("m-%d", i))
+	}
+	if err := ld.Load(ctx, rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	members, _ := mr.ZMembers("scores")
+	if len(members) != n {
+		t.Fatalf("got %d members, want %d", len(members), n)
+	}
+	for _, m := range members {
+		if strings.HasPrefix(m, "old-") {
+			t.Errorf("stale member %s was not removed", m)
+		}
+	}
+	if keys := mr.Keys(); len(keys) != 1 {
+		t.Errorf("the member set should be removed, keys %v", keys)
+	}
+}
@@ -0,0 +1,56 @@
+package loader
+
+import (
+	"context"
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
+	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
+)
+
+// RowLoader writes one Redis key per row, named by a key template such as
+// "customer:{region}:{customer_id}". With Fields each key is a hash of those
+// fields; with ValueField each key is a string.
+type RowLoader struct {
+	Template   config.KeyTemplate
+	Fields     []string
+	ValueField string
+	BatchSize  int
+	Encoder    *pgvalue.Encoder
+}
+
+func (l *RowLoader) Load(ctx context.Context, rows *rowstream.Stream, cfg config.TaskConfig, r *redis.RedisClient) error {
+	return writeBatches(ctx, r, cfg.EffectiveKeyTemplate(), rows, l.BatchSize, newCheckpoint(cfg), func(batch []map[string]any, offset int) ([]queuedCmd, error) {
+		var cmds []queuedCmd
+		for i, row := range batch {
+			key, ok := l.Template.Render(func(field string) (string, bool) {
+				return columnKey(l.Encoder, cfg, row, field)
+			})
+			if !ok {
+				continue
+			}
+
+			if l.ValueField != "" {
+				val, ok := columnValue(l.Encoder, cfg, row, l.ValueField)
+				if !ok {
+					continue
+				}
+				cmds = append(cmds, queuedCmd{cmd: goredis.NewStatusCmd(ctx, "set", key, val), firstRow: offset + i, lastRow: offset + i})
+				continue
+			}
+
+			fields := make(map[string]any, len(l.Fields))
+			for _, logical := range l.Fields {
+				if val, ok := columnValue(l.Encoder, cfg, row, logical); ok {
+					fields[logical] = val
+				}
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			cmds = append(cmds, queuedCmd{cmd: hsetCmd(ctx, key, fields), firstRow: offset + i, lastRow: offset + i})
+		}
+		return cmds, nil
+	})
+}
@@ -0,0 +1,69 @@
+package loader
+
+import (
+	"context"
+	"testing"
+
+	"red-courier/internal/config"
+	"red-courier/internal/rowstream"
+)
+
+func TestRowLoader_HashPerRow(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	cfg := config.TaskConfig{
+		Name:        "customers_by_id",
+		Structure:   "row",
+		KeyTemplate: "customer:{region}:{customer_id}",
+		Fields:      []string{"customer_id", "display_name"},
+		ColumnMap:   map[string]string{"display_name": "name"},
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	rows := []map[string]any{
+		{"region": "eu", "customer_id": int64(42), "name": "Ada"},
+		{"region": nil, "customer_id": int64(43), "name": "no region"},
+	}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := mr.HGet("customer:eu:42", "display_name"); got != "Ada" {
+		t.Errorf("display_name: got %q want %q", got, "Ada")
+	}
+	if got := mr.HGet("customer:eu:42", "customer_id"); got != "42" {
+		t.Errorf("customer_id: got %q want %q", got, "42")
+	}
+	if keys := mr.Keys(); len(keys) != 1 {
+		t.Errorf("rows with a NULL key part should be skipped, got keys %v", keys)
+	}
+}
+
+func TestRowLoader_StringPerRow(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	cfg := config.TaskConfig{
+		Name:      "order_status",
+		Structure: "row",
+		KeyPrefix: "order_status",
+		Key:       "id",
+		Value:     "status",
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	rows := []map[string]any{{"id": int64(7), "status": "NEW"}}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got, err := mr.Get("order_status:7")
+	if err != nil || got != "NEW" {
+		t.Errorf("order_status:7: got %q (%v) want %q", got, err, "NEW")
+	}
+}
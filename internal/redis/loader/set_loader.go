@@ -2,25 +2,33 @@ package loader
 
 import (
 	"context"
-	"fmt"
+	goredis "github.com/redis/go-redis/v9"
 	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
 	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
 )
 
 type SetLoader struct {
 	ValueField string
+	BatchSize  int
+	Encoder    *pgvalue.Encoder
 }
 
-func (l *SetLoader) Load(ctx context.Context, rows []map[string]any, cfg config.TaskConfig, r *redis.RedisClient) error {
+func (l *SetLoader) Load(ctx context.Context, rows *rowstream.Stream, cfg config.TaskConfig, r *redis.RedisClient) error {
 	key := cfg.EffectiveRedisKey()
-	for _, row := range rows {
-		val, ok := row[cfg.ResolveColumn(cfg.Value)]
-		if !ok {
-			continue
+	return writeBatches(ctx, r, key, rows, l.BatchSize, newCheckpoint(cfg), func(batch []map[string]any, offset int) ([]queuedCmd, error) {
+		members := make([]any, 0, len(batch))
+		for _, row := range batch {
+			val, ok := columnValue(l.Encoder, cfg, row, cfg.Value)
+			if !ok {
+				continue
+			}
+			members = append(members, val)
 		}
-		if err := r.Client.SAdd(ctx, key, val).Err(); err != nil {
-			return fmt.Errorf("failed to SADD to Redis set: %w", err)
+		if len(members) == 0 {
+			return nil, nil
 		}
-	}
-	return nil
+		return []queuedCmd{{cmd: goredis.NewIntCmd(ctx, append([]any{"sadd", key}, members...)...), firstRow: offset, lastRow: offset + len(batch) - 1}}, nil
+	})
 }
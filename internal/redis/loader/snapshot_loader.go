@@ -0,0 +1,58 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/config"
+	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
+)
+
+// SnapshotLoader replaces the live key with a complete copy of the result set.
+// Rows are written into a temporary key by the inner loader, which is then
+// RENAMEd over the live key so readers never see a partially loaded snapshot.
+type SnapshotLoader struct {
+	Inner Loader
+}
+
+func (l *SnapshotLoader) Load(ctx context.Context, rows *rowstream.Stream, cfg config.TaskConfig, r *redis.RedisClient) error {
+	key := cfg.EffectiveRedisKey()
+	tmpKey := SnapshotTempKey(key)
+
+	// Clear anything left behind by a run that died before its RENAME.
+	if err := write(ctx, r, tmpKey, goredis.NewIntCmd(ctx, "del", tmpKey)); err != nil {
+		return fmt.Errorf("failed to clear snapshot temp key: %w", err)
+	}
+
+	tmpCfg := cfg
+	tmpCfg.Alias = tmpKey
+	if err := l.Inner.Load(ctx, rows, tmpCfg, r); err != nil {
+		_ = write(ctx, r, tmpKey, goredis.NewIntCmd(ctx, "del", tmpKey))
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	// An empty result set means the snapshot is empty; there is nothing to rename.
+	n, err := r.Client.Exists(ctx, tmpKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check snapshot temp key: %w", err)
+	}
+	if n == 0 {
+		if err := write(ctx, r, key, goredis.NewIntCmd(ctx, "del", key)); err != nil {
+			return fmt.Errorf("failed to clear empty snapshot: %w", err)
+		}
+		return nil
+	}
+
+	if err := write(ctx, r, key, goredis.NewStatusCmd(ctx, "rename", tmpKey, key)); err != nil {
+		_ = write(ctx, r, tmpKey, goredis.NewIntCmd(ctx, "del", tmpKey))
+		return fmt.Errorf("failed to RENAME snapshot into place: %w", err)
+	}
+	return nil
+}
+
+// SnapshotTempKey is the key a snapshot of key is built in before being renamed.
+func SnapshotTempKey(key string) string {
+	return key + ":snapshot:tmp"
+}
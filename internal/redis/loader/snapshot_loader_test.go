@@ -0,0 +1,120 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"red-courier/internal/config"
+	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.RedisClient) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	r := redis.NewRedisClient(redis.RedisConfig{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = r.Close() })
+	return mr, r
+}
+
+func TestSnapshotLoader_ReplacesLiveKey(t *testing.T) {
+	mr, r := newTestRedis(t)
+	ctx := context.Background()
+
+	cfg := config.TaskConfig{
+		Name:      "customer_snapshot",
+		Table:     "public.customers",
+		Alias:     "customers",
+		Structure: "snapshot",
+		Key:       "id",
+		Value:     "name",
+		Snapshot:  &config.SnapshotConfig{Structure: "map"},
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	mr.HSet("customers", "stale", "gone")
+
+	rows := []map[string]any{
+		{"id": "1", "name": "Ada"},
+		{"id": "2", "name": "Grace"},
+	}
+	if err := ld.Load(ctx, rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got, _ := mr.HKeys("customers"); len(got) != 2 {
+		t.Fatalf("expected 2 fields after snapshot, got %v", got)
+	}
+	if mr.HGet("customers", "stale") != "" {
+		t.Errorf("stale field should be gone after snapshot")
+	}
+	if mr.Exists(SnapshotTempKey("customers")) {
+		t.Errorf("temp key should not survive a successful snapshot")
+	}
+}
+
+func TestSnapshotLoader_EmptyResultClearsKey(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	cfg := config.TaskConfig{
+		Name:      "trades_snapshot",
+		Table:     "public.trades",
+		Structure: "snapshot",
+		Fields:    []string{"id"},
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	mr.Set("public.trades", "old")
+	if err := ld.Load(context.Background(), rowstream.FromSlice(nil), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if mr.Exists("public.trades") {
+		t.Errorf("empty snapshot should clear the live key")
+	}
+}
+
+func TestSnapshotLoader_FailedQueryKeepsLiveKey(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	cfg := config.TaskConfig{
+		Name:      "trades_snapshot",
+		Table:     "public.trades",
+		Structure: "snapshot",
+		Fields:    []string{"id"},
+		BatchSize: 1,
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	mr.Set("public.trades", "old")
+	boom := errors.New("connection reset")
+	rows := rowstream.New(context.Background(), 1, func(ctx context.Context, emit func(map[string]any) error) error {
+		for i := 0; i < 3; i++ {
+			if err := emit(map[string]any{"id": i}); err != nil {
+				return err
+			}
+		}
+		return boom
+	})
+	defer rows.Close()
+
+	if err := ld.Load(context.Background(), rows, cfg, r); !errors.Is(err, boom) {
+		t.Fatalf("Load error = %v, want %v", err, boom)
+	}
+	if got, _ := mr.Get("public.trades"); got != "old" {
+		t.Errorf("live key replaced by a partial snapshot")
+	}
+	if mr.Exists(SnapshotTempKey("public.trades")) {
+		t.Errorf("temp key should be removed after a failed snapshot")
+	}
+}
@@ -2,45 +2,57 @@ package loader
 
 import (
 	"context"
-	"fmt"
+	goredis "github.com/redis/go-redis/v9"
+	"math"
 	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
 	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
 	"strconv"
+	"time"
 )
 
 type SortedSetLoader struct {
 	ValueField string
 	ScoreField string
+	BatchSize  int
+	Encoder    *pgvalue.Encoder
 }
 
-func (l *SortedSetLoader) Load(ctx context.Context, rows []map[string]any, cfg config.TaskConfig, r *redis.RedisClient) error {
+func (l *SortedSetLoader) Load(ctx context.Context, rows *rowstream.Stream, cfg config.TaskConfig, r *redis.RedisClient) error {
 	key := cfg.EffectiveRedisKey()
-	for _, row := range rows {
-		val, valOk := row[cfg.ResolveColumn(cfg.Value)]
-		scoreRaw, scoreOk := row[cfg.ResolveColumn(cfg.Score)]
-		if !valOk || !scoreOk {
-			continue
-		}
+	return writeBatches(ctx, r, key, rows, l.BatchSize, newCheckpoint(cfg), func(batch []map[string]any, offset int) ([]queuedCmd, error) {
+		members := make([]goredis.Z, 0, len(batch))
+		for _, row := range batch {
+			val, valOk := columnValue(l.Encoder, cfg, row, cfg.Value)
+			scoreRaw, scoreOk := row[cfg.ResolveColumn(cfg.Score)]
+			if !valOk || !scoreOk {
+				continue
+			}
 
-		var score float64
-		switch s := scoreRaw.(type) {
-		case float64:
-			score = s
-		case int64:
-			score = float64(s)
-		case string:
-			parsed, err := strconv.ParseFloat(s, 64)
-			if err != nil {
+			score, ok := scoreOf(scoreRaw)
+			if !ok {
 				continue
 			}
-			score = parsed
-		default:
-			continue
+			members = append(members, goredis.Z{Score: score, Member: val})
 		}
-
-		if err := r.AddToSortedSet(ctx, key, score, val); err != nil {
-			return fmt.Errorf("failed to ZADD to Redis sorted set: %w", err)
+		if len(members) == 0 {
+			return nil, nil
 		}
+		return []queuedCmd{{cmd: zaddCmd(ctx, key, members...), firstRow: offset, lastRow: offset + len(batch) - 1}}, nil
+	})
+}
+
+// scoreOf converts a score column value to a float: numbers of any width, numeric
+// and numeric text as they are, timestamps as (fractional) seconds since the epoch.
+// It reports false for NULL and values that are not numbers.
+func scoreOf(raw any) (float64, bool) {
+	switch s := raw.(type) {
+	case nil:
+		return 0, false
+	case time.Time:
+		return float64(s.UnixNano()) / 1e9, true
 	}
-	return nil
+	parsed, err := strconv.ParseFloat(pgvalue.Default.String(raw), 64)
+	return parsed, err == nil && !math.IsNaN(parsed)
 }
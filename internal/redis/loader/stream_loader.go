@@ -3,39 +3,166 @@ package loader
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
+
 	goredis "github.com/redis/go-redis/v9"
 	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
 	"red-courier/internal/redis"
+	"red-courier/internal/rowstream"
 )
 
 type StreamLoader struct {
-	Fields []string
+	Fields    []string
+	BatchSize int
+	Encoder   *pgvalue.Encoder
+	Options   *config.StreamConfig // trimming, entry IDs and dedup; nil for plain XADD
 }
 
-func (l *StreamLoader) Load(ctx context.Context, rows []map[string]any, cfg config.TaskConfig, r *redis.RedisClient) error {
+func (l *StreamLoader) Load(ctx context.Context, rows *rowstream.Stream, cfg config.TaskConfig, r *redis.RedisClient) error {
 	key := cfg.EffectiveRedisKey()
-	for _, row := range rows {
-		fields := make(map[string]any)
-		for _, logical := range cfg.Fields {
-			col := cfg.ResolveColumn(logical)
-			val, ok := row[col]
-			if !ok {
+	return writeBatches(ctx, r, key, rows, l.BatchSize, newCheckpoint(cfg), func(batch []map[string]any, offset int) ([]queuedCmd, error) {
+		now := time.Now()
+		seen, err := l.published(ctx, r, cfg, batch, now)
+		if err != nil {
+			return nil, err
+		}
+
+		var cmds []queuedCmd
+		for i, row := range batch {
+			fields := make(map[string]any)
+			for _, logical := range cfg.Fields {
+				val, ok := columnValue(l.Encoder, cfg, row, logical)
+				if !ok {
+					continue
+				}
+				fields[logical] = val
+			}
+
+			if len(fields) == 0 {
 				continue
 			}
-			fields[logical] = val
-		}
 
-		if len(fields) == 0 {
-			continue
+			args := &goredis.XAddArgs{
+				Stream: key,
+				Values: fields,
+			}
+			trimStream(args, l.Options, now)
+			var id string
+			if o := l.Options; o != nil && o.IDColumn != "" {
+				var ok bool
+				if id, ok = entryID(cfg, o, row); !ok {
+					continue
+				}
+				args.ID = id
+			}
+
+			var dedupKey string
+			if d := l.dedup(); d != nil {
+				var ok bool
+				if dedupKey, ok = columnKey(l.Encoder, cfg, row, d.Column); ok {
+					if seen[dedupKey] {
+						continue
+					}
+					seen[dedupKey] = true
+				}
+			}
+
+			cmds = append(cmds, queuedCmd{cmd: xaddCmd(ctx, args), firstRow: offset + i, lastRow: offset + i, entryID: id})
+			if dedupKey != "" {
+				z := goredis.Z{Score: float64(now.UnixMilli()), Member: dedupKey}
+				cmds = append(cmds, queuedCmd{cmd: zaddCmd(ctx, l.dedup().Key, z), firstRow: offset + i, lastRow: offset + i})
+			}
 		}
+		if d := l.dedup(); d != nil && len(cmds) > 0 {
+			cutoff := strconv.FormatInt(now.Add(-d.EffectiveWindow()).UnixMilli(), 10)
+			cmd := goredis.NewIntCmd(ctx, "zremrangebyscore", d.Key, "-inf", "("+cutoff)
+			cmds = append(cmds, queuedCmd{cmd: cmd, firstRow: offset, lastRow: offset + len(batch) - 1})
+		}
+		return cmds, nil
+	})
+}
+
+func (l *StreamLoader) dedup() *config.DedupConfig {
+	if l.Options == nil {
+		return nil
+	}
+	return l.Options.Dedup
+}
 
-		args := &goredis.XAddArgs{
-			Stream: key,
-			Values: fields,
+// published returns the batch's keys that were published within the dedup window.
+// Keys are recorded in the same call as their entries, so a batch that failed to
+// commit is published again when it is re-sent.
+func (l *StreamLoader) published(ctx context.Context, r *redis.RedisClient, cfg config.TaskConfig, batch []map[string]any, now time.Time) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	d := l.dedup()
+	if d == nil {
+		return seen, nil
+	}
+	var keys []string
+	for _, row := range batch {
+		if k, ok := columnKey(l.Encoder, cfg, row, d.Column); ok {
+			keys = append(keys, k)
 		}
-		if err := r.Client.XAdd(ctx, args).Err(); err != nil {
-			return fmt.Errorf("failed to XADD to Redis stream: %w", err)
+	}
+	if len(keys) == 0 {
+		return seen, nil
+	}
+	scores, err := r.Client.ZMScore(ctx, d.Key, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read dedup key %s: %w", d.Key, err)
+	}
+	cutoff := float64(now.Add(-d.EffectiveWindow()).UnixMilli())
+	for i, score := range scores {
+		// ZMSCORE reports a missing member as 0.
+		if score > 0 && score >= cutoff {
+			seen[keys[i]] = true
 		}
 	}
-	return nil
+	return seen, nil
+}
+
+// trimStream applies the task's approximate trimming to an XADD.
+func trimStream(args *goredis.XAddArgs, o *config.StreamConfig, now time.Time) {
+	if o == nil {
+		return
+	}
+	switch {
+	case o.MaxLen > 0:
+		args.MaxLen = o.MaxLen
+		args.Approx = true
+	case o.MaxAgeDuration() > 0:
+		args.MinID = strconv.FormatInt(now.Add(-o.MaxAgeDuration()).UnixMilli(), 10)
+		args.Approx = true
+	}
+}
+
+// entryID returns the stream entry ID for row: the milliseconds from id_column and
+// the sequence number from id_sequence_column. ok is false when a column is NULL
+// or not a usable number.
+func entryID(cfg config.TaskConfig, o *config.StreamConfig, row map[string]any) (string, bool) {
+	ms, ok := idPart(row[cfg.ResolveColumn(o.IDColumn)])
+	if !ok {
+		return "", false
+	}
+	seq, ok := idPart(row[cfg.ResolveColumn(o.IDSequenceColumn)])
+	if !ok {
+		return "", false
+	}
+	return strconv.FormatUint(ms, 10) + "-" + strconv.FormatUint(seq, 10), true
+}
+
+// idPart reads one half of an entry ID: a timestamp as milliseconds since the
+// epoch, or a non-negative integer as is.
+func idPart(v any) (uint64, bool) {
+	switch val := v.(type) {
+	case nil:
+		return 0, false
+	case time.Time:
+		ms := val.UnixMilli()
+		return uint64(ms), ms >= 0
+	}
+	n, err := strconv.ParseUint(pgvalue.Default.String(v), 10, 64)
+	return n, err == nil
 }
@@ -0,0 +1,208 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"red-courier/internal/config"
+	"red-courier/internal/rowstream"
+)
+
+func TestStreamLoader_EntryIDsRejectReplays(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	base := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	for _, tracking := range []*config.TrackingConfig{nil, {Columns: []string{"created_at", "id"}, Operator: ">=", LastValueKey: "checkpoint:order_events"}} {
+		mr.FlushAll()
+		cfg := config.TaskConfig{
+			Name:      "order_events",
+			Structure: "stream",
+			Alias:     "order_events",
+			Fields:    []string{"id", "created_at"},
+			Tracking:  tracking,
+			Stream:    &config.StreamConfig{IDColumn: "created_at", IDSequenceColumn: "id"},
+		}
+		ld, err := NewLoader(cfg)
+		if err != nil {
+			t.Fatalf("NewLoader: %v", err)
+		}
+
+		rows := []map[string]any{
+			{"id": int64(1), "created_at": base},
+			{"id": int64(2), "created_at": base},
+			{"id": int64(3), "created_at": base.Add(time.Millisecond)},
+			{"id": nil, "created_at": base.Add(time.Second)},
+		}
+		// The second load re-sends every row, as a run after a crash would.
+		for run := 0; run < 2; run++ {
+			if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+				t.Fatalf("tracking=%v run %d: Load: %v", tracking != nil, run, err)
+			}
+		}
+
+		entries, err := mr.Stream("order_events")
+		if err != nil {
+			t.Fatalf("Stream: %v", err)
+		}
+		ms := base.UnixMilli()
+		want := []string{
+			formatID(ms, 1),
+			formatID(ms, 2),
+			formatID(ms+1, 3),
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("tracking=%v: got %d entries want %d (replays and NULL ids should be skipped)", tracking != nil, len(entries), len(want))
+		}
+		for i, e := range entries {
+			if e.ID != want[i] {
+				t.Errorf("tracking=%v: entry %d: got ID %s want %s", tracking != nil, i, e.ID, want[i])
+			}
+		}
+	}
+}
+
+func TestStreamLoader_OutOfOrderEntryFails(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	cfg := config.TaskConfig{
+		Name:      "ticks",
+		Structure: "stream",
+		Alias:     "ticks",
+		Fields:    []string{"px"},
+		Stream:    &config.StreamConfig{IDColumn: "ts_ms", IDSequenceColumn: "id"},
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	rows := []map[string]any{
+		{"ts_ms": int64(1000), "id": int64(1), "px": "1.1"},
+		{"ts_ms": int64(1000), "id": int64(5), "px": "1.2"},
+		{"ts_ms": int64(1001), "id": int64(6), "px": "1.3"},
+	}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+
+	// 1000-3 was never written and is below the stream's top: it is not a replay.
+	late := []map[string]any{{"ts_ms": int64(1000), "id": int64(3), "px": "1.4"}}
+	err = ld.Load(context.Background(), rowstream.FromSlice(late), cfg, r)
+	var be *BatchError
+	if !errors.As(err, &be) || be.FirstRow != 0 || !strings.Contains(err.Error(), "never written") {
+		t.Fatalf("out-of-order row: got %v", err)
+	}
+	if entries, _ := mr.Stream("ticks"); len(entries) != 3 {
+		t.Errorf("stream: got %d entries want 3", len(entries))
+	}
+
+	// Entries trimmed away by max_len are replays too.
+	cfg.Alias = "ticks_trimmed"
+	cfg.Stream.MaxLen = 1
+	for run := 0; run < 2; run++ {
+		if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+			t.Fatalf("trimmed run %d: %v", run, err)
+		}
+	}
+}
+
+func TestStreamLoader_MaxLen(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	cfg := config.TaskConfig{
+		Name:      "orders",
+		Structure: "stream",
+		Alias:     "orders",
+		Fields:    []string{"id"},
+		BatchSize: 2,
+		Stream:    &config.StreamConfig{MaxLen: 3},
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	var rows []map[string]any
+	for i := 0; i < 10; i++ {
+		rows = append(rows, map[string]any{"id": int64(i)})
+	}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	entries, _ := mr.Stream("orders")
+	if len(entries) > 3 {
+		t.Errorf("stream should be trimmed to about 3 entries, got %d", len(entries))
+	}
+}
+
+func TestStreamLoader_Dedup(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	cfg := config.TaskConfig{
+		Name:      "orders",
+		Structure: "stream",
+		Alias:     "orders",
+		Fields:    []string{"id", "status"},
+		Stream:    &config.StreamConfig{Dedup: &config.DedupConfig{Column: "id", Key: "dedup:orders"}},
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	first := []map[string]any{
+		{"id": int64(1), "status": "NEW"},
+		{"id": int64(1), "status": "NEW"},
+		{"id": int64(2), "status": "NEW"},
+	}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(first), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	second := []map[string]any{
+		{"id": int64(2), "status": "NEW"},
+		{"id": int64(3), "status": "NEW"},
+	}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(second), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	entries, _ := mr.Stream("orders")
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, entryField(e.Values, "id"))
+	}
+	if len(ids) != 3 || ids[0] != "1" || ids[1] != "2" || ids[2] != "3" {
+		t.Errorf("published ids: got %v want [1 2 3]", ids)
+	}
+	if members, _ := mr.ZMembers("dedup:orders"); len(members) != 3 {
+		t.Errorf("dedup key: got members %v want 3", members)
+	}
+
+	// A key remembered for longer than the window is published again.
+	mr.ZAdd("dedup:orders", float64(time.Now().Add(-48*time.Hour).UnixMilli()), "3")
+	if err := ld.Load(context.Background(), rowstream.FromSlice(second[1:]), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if entries, _ := mr.Stream("orders"); len(entries) != 4 {
+		t.Errorf("expired key should be published again, got %d entries", len(entries))
+	}
+}
+
+func formatID(ms int64, seq int) string {
+	return fmt.Sprintf("%d-%d", ms, seq)
+}
+
+// entryField returns a field of a stream entry, whose values alternate name and value.
+func entryField(values []string, name string) string {
+	for i := 0; i+1 < len(values); i += 2 {
+		if values[i] == name {
+			return values[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,22 @@
+package loader
+
+import (
+	"red-courier/internal/config"
+	"red-courier/internal/pgvalue"
+)
+
+// columnValue returns the encoded value of a logical column. ok is false when the
+// row lacks the column or the value is a NULL the task skips.
+func columnValue(enc *pgvalue.Encoder, cfg config.TaskConfig, row map[string]any, logical string) (string, bool) {
+	v, present := row[cfg.ResolveColumn(logical)]
+	if !present {
+		return "", false
+	}
+	return enc.Encode(v)
+}
+
+// columnKey returns the encoded value of a logical column that names something in
+// Redis (a key part, hash field or member). ok is false when it is missing or NULL.
+func columnKey(enc *pgvalue.Encoder, cfg config.TaskConfig, row map[string]any, logical string) (string, bool) {
+	return enc.Key(row[cfg.ResolveColumn(logical)])
+}
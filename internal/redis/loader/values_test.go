@@ -0,0 +1,86 @@
+package loader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"red-courier/internal/config"
+	"red-courier/internal/rowstream"
+)
+
+func TestLoaders_EncodeValues(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	var amount pgtype.Numeric
+	if err := amount.Scan("19.99"); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	cfg := config.TaskConfig{
+		Name:      "orders",
+		Alias:     "orders",
+		Structure: "stream",
+		Fields:    []string{"id", "amount", "paid_at", "note"},
+		Encoding:  &config.EncodingConfig{TimeFormat: "unix_ms", Timezone: "UTC", Nulls: "skip"},
+	}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	rows := []map[string]any{{
+		"id":      [16]byte{0xff},
+		"amount":  amount,
+		"paid_at": time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC),
+		"note":    nil,
+	}}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	entries, err := mr.Stream("orders")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one entry, got %v (%v)", entries, err)
+	}
+	got := sortedPairs(entries[0].Values)
+	want := []string{"amount", "19.99", "id", "ff000000-0000-0000-0000-000000000000", "paid_at", "1758153600000"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+}
+
+func TestSortedSetLoader_Scores(t *testing.T) {
+	mr, r := newTestRedis(t)
+
+	var score pgtype.Numeric
+	if err := score.Scan("2.5"); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	cfg := config.TaskConfig{Name: "ranks", Alias: "ranks", Structure: "sorted_set", Value: "name", Score: "score"}
+	ld, err := NewLoader(cfg)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	rows := []map[string]any{
+		{"name": "int4", "score": int32(3)},
+		{"name": "numeric", "score": score},
+		{"name": "timestamp", "score": time.Unix(1758153600, 0)},
+		{"name": "null", "score": nil},
+	}
+	if err := ld.Load(context.Background(), rowstream.FromSlice(rows), cfg, r); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for member, want := range map[string]float64{"int4": 3, "numeric": 2.5, "timestamp": 1758153600} {
+		if got, err := mr.ZScore("ranks", member); err != nil || got != want {
+			t.Errorf("%s: got %v (%v) want %v", member, got, err, want)
+		}
+	}
+	if members, _ := mr.ZMembers("ranks"); len(members) != 3 {
+		t.Errorf("a NULL score should skip the row, got members %v", members)
+	}
+}
@@ -0,0 +1,102 @@
+package rowstream
+
+import "context"
+
+// Stream carries rows from a producer, typically a Postgres query, to a consumer,
+// typically a Redis loader, through a bounded buffer. The producer blocks once the
+// buffer is full, so reading the query never runs more than one buffer ahead of
+// the writes and memory stays bounded regardless of the result size.
+type Stream struct {
+	rows   chan map[string]any
+	done   chan struct{}
+	cancel context.CancelFunc
+	err    error
+	n      int
+}
+
+// New runs produce in its own goroutine. produce passes each row to emit, which
+// blocks while the buffer is full and returns an error once the stream is closed
+// or ctx is done; produce should then stop and return that error.
+func New(ctx context.Context, buffer int, produce func(ctx context.Context, emit func(map[string]any) error) error) *Stream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Stream{
+		rows:   make(chan map[string]any, max(buffer, 0)),
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	go func() {
+		defer cancel()
+		s.err = produce(ctx, func(row map[string]any) error {
+			select {
+			case s.rows <- row:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(s.done)
+		close(s.rows)
+	}()
+	return s
+}
+
+// FromSlice returns a stream over rows that are already in memory.
+func FromSlice(rows []map[string]any) *Stream {
+	s := &Stream{
+		rows:   make(chan map[string]any, len(rows)),
+		done:   make(chan struct{}),
+		cancel: func() {},
+	}
+	for _, row := range rows {
+		s.rows <- row
+	}
+	close(s.done)
+	close(s.rows)
+	return s
+}
+
+// Next returns the next row, blocking until the producer sends one. It returns
+// false once the producer has finished; check Err to tell completion from failure.
+func (s *Stream) Next() (map[string]any, bool) {
+	row, ok := <-s.rows
+	if ok {
+		s.n++
+	}
+	return row, ok
+}
+
+// Err returns the producer's error once it has finished, and nil before that.
+func (s *Stream) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Count returns the number of rows received so far.
+func (s *Stream) Count() int {
+	return s.n
+}
+
+// Close stops the producer and discards any buffered rows. It is safe to call
+// after the stream has been fully read.
+func (s *Stream) Close() {
+	s.cancel()
+	for range s.rows {
+	}
+}
+
+// Collect reads the remaining rows into a slice.
+func (s *Stream) Collect() ([]map[string]any, error) {
+	var rows []map[string]any
+	for {
+		row, ok := s.Next()
+		if !ok {
+			break
+		}
+		rows = append(rows, row)
+	}
+	return rows, s.Err()
+}
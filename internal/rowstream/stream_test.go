@@ -0,0 +1,96 @@
+package rowstream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStream_BackPressure(t *testing.T) {
+	sent := make(chan int, 100)
+	s := New(context.Background(), 2, func(ctx context.Context, emit func(map[string]any) error) error {
+		for i := 0; i < 10; i++ {
+			if err := emit(map[string]any{"id": i}); err != nil {
+				return err
+			}
+			sent <- i
+		}
+		return nil
+	})
+	defer s.Close()
+
+	// With nothing read, the producer fills the buffer and then blocks.
+	time.Sleep(20 * time.Millisecond)
+	if n := len(sent); n != 2 {
+		t.Fatalf("producer ran ahead: sent %d rows with buffer 2", n)
+	}
+
+	rows, err := s.Collect()
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(rows) != 10 || s.Count() != 10 {
+		t.Fatalf("got %d rows (count %d), want 10", len(rows), s.Count())
+	}
+	for i, row := range rows {
+		if row["id"] != i {
+			t.Fatalf("row %d out of order: %v", i, row)
+		}
+	}
+}
+
+func TestStream_ProducerError(t *testing.T) {
+	boom := errors.New("connection reset")
+	s := New(context.Background(), 1, func(ctx context.Context, emit func(map[string]any) error) error {
+		if err := emit(map[string]any{"id": 1}); err != nil {
+			return err
+		}
+		return boom
+	})
+	defer s.Close()
+
+	rows, err := s.Collect()
+	if !errors.Is(err, boom) {
+		t.Fatalf("Collect error = %v, want %v", err, boom)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want the 1 row sent before the error", len(rows))
+	}
+}
+
+func TestStream_CloseStopsProducer(t *testing.T) {
+	stopped := make(chan error, 1)
+	s := New(context.Background(), 0, func(ctx context.Context, emit func(map[string]any) error) error {
+		for {
+			if err := emit(map[string]any{}); err != nil {
+				stopped <- err
+				return err
+			}
+		}
+	})
+	if _, ok := s.Next(); !ok {
+		t.Fatalf("expected a row")
+	}
+	s.Close()
+
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("emit error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("producer still running after Close")
+	}
+}
+
+func TestFromSlice(t *testing.T) {
+	s := FromSlice([]map[string]any{{"id": 1}, {"id": 2}})
+	rows, err := s.Collect()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("got %d rows, err %v", len(rows), err)
+	}
+	if _, ok := s.Next(); ok {
+		t.Fatalf("expected end of stream")
+	}
+}
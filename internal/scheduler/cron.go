@@ -4,26 +4,65 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron/v3"
 	"red-courier/internal/config"
 	"red-courier/internal/db"
+	"red-courier/internal/leader"
 	"red-courier/internal/redis"
 	"red-courier/internal/task"
 )
 
+const defaultSchedule = "@every 5m"
+
+// scheduledReason prefixes the reason of runs started by the task's schedule.
+const scheduledReason = "schedule: "
+
 type Scheduler struct {
 	cron    *cron.Cron
-	tasks   []*task.Task
 	context context.Context
+	db      *db.Database
+	redis   *redis.RedisClient
+
+	mu       sync.Mutex
+	entries  map[string]*entry // keyed by task name
+	started  bool
+	listener *listener  // nil when no task has a trigger
+	active   int        // runs and CDC streams in flight
+	idle     *sync.Cond // signalled when active drops to zero
+
+	runs    *runs
+	runTask func(ctx context.Context, t *task.Task) error
+	locker  *leader.Locker // set when runs take per-task locks
+}
+
+// entry is a task registered with cron, or a CDC task streaming in the background.
+type entry struct {
+	task       *task.Task
+	id         cron.EntryID
+	trigger    *debouncer         // set when the task has a trigger
+	stopStream context.CancelFunc // set while a CDC task is streaming
 }
 
 func NewScheduler(ctx context.Context, cfg *config.Config, db *db.Database, redis *redis.RedisClient) (*Scheduler, error) {
 	s := &Scheduler{
 		cron:    cron.New(),
-		tasks:   []*task.Task{},
 		context: ctx,
+		db:      db,
+		redis:   redis,
+		entries: make(map[string]*entry),
+		runs:    newRuns(cfg.Scheduler.EffectiveMaxConcurrentTasks()),
+		runTask: func(ctx context.Context, t *task.Task) error { return t.Run(ctx) },
+	}
+	s.idle = sync.NewCond(&s.mu)
+	if tl := cfg.Scheduler.TaskLocks; tl != nil && tl.Enabled && redis != nil {
+		s.locker = leader.NewLocker(redis, tl.EffectiveKeyPrefix(), tl.ID, tl.EffectiveTTL())
+		log.Printf("Task locks enabled: %s takes %s<task> before each run", s.locker.ID(), tl.EffectiveKeyPrefix())
 	}
 
 	for _, tcfg := range cfg.Tasks {
@@ -31,41 +70,283 @@ func NewScheduler(ctx context.Context, cfg *config.Config, db *db.Database, redi
 		if err != nil {
 			return nil, err
 		}
-		s.tasks = append(s.tasks, t)
+		if err := s.schedule(t); err != nil {
+			return nil, err
+		}
+	}
 
-		schedule := tcfg.Schedule
-		if schedule == "" {
-			schedule = "@every 5m"
+	return s, nil
+}
+
+// schedule registers t with cron under its task name. Callers must hold s.mu
+// or have exclusive access to the scheduler.
+func (s *Scheduler) schedule(t *task.Task) error {
+	if t.Config.CDC != nil {
+		log.Printf("Task %s streams changes from replication slot %s", t.Config.Name, t.Config.CDC.Slot)
+		e := &entry{task: t}
+		s.entries[t.Config.Name] = e
+		s.startStream(e)
+		return nil
+	}
+	schedule := scheduleOf(t.Config)
+
+	log.Printf("Scheduling task %s to run %s", t.Config.Name, schedule)
+	id, err := s.cron.AddFunc(schedule, func() {
+		if s.delay(t.Config.JitterDuration()) {
+			s.run(t, scheduledReason+schedule)
 		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule task %s: %w", t.Config.Name, err)
+	}
 
-		log.Printf("Scheduling task %s to run %s", tcfg.Name, schedule)
-		_, err = s.cron.AddFunc(schedule, func(taskToRun *task.Task) func() {
-			return func() {
-				ctx, cancel := context.WithTimeout(s.context, 1*time.Minute)
-				defer cancel()
+	e := &entry{task: t, id: id}
+	if trig := t.Config.Trigger; trig != nil {
+		log.Printf("Task %s also runs on NOTIFY %s", t.Config.Name, trig.Listen)
+		e.trigger = &debouncer{wait: trig.EffectiveDebounce(), fn: func() {
+			s.run(t, "notify: "+trig.Listen)
+		}}
+	}
+	s.entries[t.Config.Name] = e
+	return nil
+}
 
-				log.Printf("Running scheduled task for: %s (schedule: %s)", taskToRun.Config.Table, schedule)
-				if err := taskToRun.Run(ctx); err != nil {
-					log.Printf("Error in task %s: %v", taskToRun.Config.Table, err)
-				}
-			}
-		}(t))
+// delay waits a random time of up to max before a scheduled run, so tasks due at
+// the same moment do not all query Postgres at once. It reports false if the
+// scheduler stopped meanwhile.
+func (s *Scheduler) delay(max time.Duration) bool {
+	if max <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(rand.N(max)):
+		return true
+	case <-s.runContext().Done():
+		return false
+	}
+}
+
+// unschedule removes e from cron and cancels any pending triggered run, or stops
+// a CDC task's stream.
+func (s *Scheduler) unschedule(e *entry) {
+	if e.task.Config.CDC == nil {
+		s.cron.Remove(e.id)
+	}
+	if e.trigger != nil {
+		e.trigger.stop()
+	}
+	if e.stopStream != nil {
+		e.stopStream()
+		e.stopStream = nil
+	}
+}
+
+// startStream starts streaming a CDC task once the scheduler has started. With
+// task locks, the task streams on whichever replica holds its lock. Callers must
+// hold s.mu.
+func (s *Scheduler) startStream(e *entry) {
+	if !s.started || e.stopStream != nil || s.db == nil || s.db.Pool == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(s.context)
+	e.stopStream = cancel
+	stream := func(ctx context.Context) {
+		if err := e.task.Stream(ctx); err != nil {
+			log.Printf("Error in task %s: %v", e.task.Config.Name, err)
+		}
+	}
+	s.active++
+	go func() {
+		defer s.done()
+		if s.locker != nil {
+			s.locker.Elector(e.task.Config.Name).Run(ctx, stream)
+			return
+		}
+		stream(ctx)
+	}()
+}
+
+// begin counts a run or stream as in flight until done is called, so Stop can
+// wait for it.
+func (s *Scheduler) begin() {
+	s.mu.Lock()
+	s.active++
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active--; s.active == 0 {
+		s.idle.Broadcast()
+	}
+}
 
+// notify runs, after their debounce, the tasks that listen on channel.
+func (s *Scheduler) notify(channel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.trigger != nil && e.task.Config.Trigger.Listen == channel {
+			e.trigger.kick()
+		}
+	}
+}
+
+// syncListener makes the LISTEN connection match the triggers of the scheduled
+// tasks. Callers must hold s.mu.
+func (s *Scheduler) syncListener() {
+	if !s.started || s.db == nil || s.db.Pool == nil {
+		return
+	}
+	var channels []string
+	for _, e := range s.entries {
+		if e.trigger != nil && !slices.Contains(channels, e.task.Config.Trigger.Listen) {
+			channels = append(channels, e.task.Config.Trigger.Listen)
+		}
+	}
+	slices.Sort(channels)
+	if s.listener.sameChannels(channels) {
+		return
+	}
+	if s.listener != nil {
+		s.listener.stop()
+		s.listener = nil
+	}
+	if len(channels) > 0 {
+		s.listener = startListener(s.context, s.db.Pool, channels, s.notify)
+	}
+}
+
+// Reload applies a new set of tasks: new tasks are scheduled, removed tasks are
+// unscheduled and changed tasks are rebuilt (loader and schedule) and rescheduled.
+// Every new or changed task is built before anything is touched, so a reload that
+// fails leaves the running schedule unchanged. Runs already in flight finish with
+// the task definition they started with.
+func (s *Scheduler) Reload(cfg *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[string]bool, len(cfg.Tasks))
+	var changed []*task.Task
+	for _, tcfg := range cfg.Tasks {
+		wanted[tcfg.Name] = true
+		if old, ok := s.entries[tcfg.Name]; ok && reflect.DeepEqual(old.task.Config, tcfg) {
+			continue
+		}
+		if tcfg.CDC == nil {
+			if _, err := cron.ParseStandard(scheduleOf(tcfg)); err != nil {
+				return fmt.Errorf("reload rejected: task %q: invalid schedule: %w", tcfg.Name, err)
+			}
+		}
+		t, err := task.NewTask(tcfg, s.db, s.redis)
 		if err != nil {
-			return nil, fmt.Errorf("failed to schedule task %s: %w", tcfg.Table, err)
+			return fmt.Errorf("reload rejected: task %q: %w", tcfg.Name, err)
 		}
+		changed = append(changed, t)
 	}
 
-	return s, nil
+	var added, updated, removed int
+	for name, e := range s.entries {
+		if !wanted[name] {
+			s.unschedule(e)
+			s.runs.forget(name)
+			delete(s.entries, name)
+			log.Printf("Unscheduled removed task %s", name)
+			removed++
+		}
+	}
+	for _, t := range changed {
+		if old, ok := s.entries[t.Config.Name]; ok {
+			s.unschedule(old)
+			s.runs.replace(t)
+			updated++
+		} else {
+			added++
+		}
+		if err := s.schedule(t); err != nil {
+			// Schedules were parsed above, so this is not expected to happen.
+			return err
+		}
+	}
+
+	s.syncListener()
+	s.runs.setLimit(cfg.Scheduler.EffectiveMaxConcurrentTasks())
+
+	log.Printf("Reloaded tasks: %d added, %d updated, %d removed", added, updated, removed)
+	return nil
+}
+
+func scheduleOf(tcfg config.TaskConfig) string {
+	if tcfg.Schedule == "" {
+		return defaultSchedule
+	}
+	return tcfg.CronSpec()
 }
 
 func (s *Scheduler) Start() {
+	s.start(s.context)
+	select {}
+}
+
+// Lead runs the tasks until ctx ends, for a replica holding the leader lease.
+// Runs, CDC streams and the NOTIFY listener use ctx, so losing the lease stops
+// them, and their writes carry the lease's fence (see leader.WithFence). Lead
+// returns only once they have all finished, so the lease is not given up while
+// this replica is still writing.
+func (s *Scheduler) Lead(ctx context.Context) {
+	s.start(ctx)
+	<-ctx.Done()
+	s.Stop()
+}
+
+func (s *Scheduler) start(ctx context.Context) {
 	log.Println("Starting scheduler...")
+	s.mu.Lock()
+	s.context = ctx
+	s.started = true
+	for _, e := range s.entries {
+		s.startStream(e)
+	}
+	s.syncListener()
+	s.mu.Unlock()
 	s.cron.Start()
-	select {}
 }
 
+// runContext returns the context task runs are started under.
+func (s *Scheduler) runContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.context
+}
+
+// Stop stops scheduling and cancels triggers and CDC streams, then waits for runs
+// and streams in flight to finish. Runs use the scheduler's context; cancel it
+// first for them to stop promptly.
 func (s *Scheduler) Stop() {
 	log.Println("Stopping scheduler...")
-	s.cron.Stop()
+	s.mu.Lock()
+	s.started = false
+	if s.listener != nil {
+		s.listener.stop()
+		s.listener = nil
+	}
+	for _, e := range s.entries {
+		if e.trigger != nil {
+			e.trigger.stop()
+		}
+		if e.stopStream != nil {
+			e.stopStream()
+			e.stopStream = nil
+		}
+	}
+	s.mu.Unlock()
+
+	// Wait for scheduled runs, then for triggered and queued runs and streams.
+	<-s.cron.Stop().Done()
+	s.mu.Lock()
+	for s.active > 0 {
+		s.idle.Wait()
+	}
+	s.mu.Unlock()
 }
@@ -0,0 +1,239 @@
+// internal/scheduler/cron_test.go
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"red-courier/internal/config"
+	"red-courier/internal/task"
+)
+
+func streamTask(name, schedule string) config.TaskConfig {
+	return config.TaskConfig{
+		Name:      name,
+		Table:     "public." + name,
+		Structure: "stream",
+		Fields:    []string{"id"},
+		Schedule:  schedule,
+	}
+}
+
+func TestReload_AddsRemovesAndReschedules(t *testing.T) {
+	cfg := &config.Config{Tasks: []config.TaskConfig{
+		streamTask("orders", "@every 10s"),
+		streamTask("quotes", "@every 1m"),
+		streamTask("trades", "@every 1h"),
+	}}
+	s, err := NewScheduler(context.Background(), cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	unchangedID := s.entries["orders"].id
+	changedID := s.entries["quotes"].id
+
+	next := &config.Config{Tasks: []config.TaskConfig{
+		streamTask("orders", "@every 10s"), // unchanged
+		streamTask("quotes", "@every 5s"),  // rescheduled
+		streamTask("fills", "@every 1m"),   // added; trades removed
+	}}
+	if err := s.Reload(next); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if len(s.entries) != 3 {
+		t.Fatalf("expected 3 tasks, got %d", len(s.entries))
+	}
+	if _, ok := s.entries["trades"]; ok {
+		t.Errorf("expected trades to be removed")
+	}
+	if _, ok := s.entries["fills"]; !ok {
+		t.Errorf("expected fills to be added")
+	}
+	if s.entries["orders"].id != unchangedID {
+		t.Errorf("unchanged task should keep its cron entry")
+	}
+	if s.entries["quotes"].id == changedID || s.entries["quotes"].task.Config.Schedule != "@every 5s" {
+		t.Errorf("changed task should be rescheduled")
+	}
+	if got := len(s.cron.Entries()); got != 3 {
+		t.Errorf("expected 3 cron entries, got %d", got)
+	}
+}
+
+func TestReload_RejectsInvalidConfigWithoutChanges(t *testing.T) {
+	cfg := &config.Config{Tasks: []config.TaskConfig{streamTask("orders", "@every 10s")}}
+	s, err := NewScheduler(context.Background(), cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+
+	bad := streamTask("quotes", "@every 10s")
+	bad.Structure = "bogus"
+	next := &config.Config{Tasks: []config.TaskConfig{bad}}
+
+	if err := s.Reload(next); err == nil {
+		t.Fatalf("expected reload to be rejected")
+	}
+	if _, ok := s.entries["orders"]; !ok || len(s.entries) != 1 {
+		t.Fatalf("rejected reload must leave tasks unchanged, got %v", s.entries)
+	}
+}
+
+func TestDebouncer_CoalescesBurst(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	d := &debouncer{wait: 20 * time.Millisecond, fn: func() {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+	}}
+
+	for i := 0; i < 10; i++ {
+		d.kick()
+	}
+	time.Sleep(60 * time.Millisecond)
+	d.kick()
+	d.stop()
+	time.Sleep(40 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("expected the burst to trigger 1 run and the stopped kick none, got %d", calls)
+	}
+}
+
+func TestDebouncer_WaitsForQuiet(t *testing.T) {
+	var calls atomic.Int32
+	d := &debouncer{wait: 40 * time.Millisecond, fn: func() { calls.Add(1) }}
+	defer d.stop()
+
+	// Kicks closer together than wait keep putting the call off.
+	for i := 0; i < 4; i++ {
+		d.kick()
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Fatalf("called %d times during the burst", got)
+	}
+	waitUntil(t, func() bool { return calls.Load() == 1 })
+
+	// A steady stream is still served after maxDebounce waits.
+	d.wait = 5 * time.Millisecond
+	start := time.Now()
+	for calls.Load() == 1 && time.Since(start) < time.Second {
+		d.kick()
+		time.Sleep(time.Millisecond)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("a steady stream of kicks starved the call")
+	}
+}
+
+func TestReload_TriggerEntries(t *testing.T) {
+	triggered := streamTask("orders", "@every 1h")
+	triggered.Trigger = &config.TriggerConfig{Listen: "orders_changed"}
+	s, err := NewScheduler(context.Background(), &config.Config{Tasks: []config.TaskConfig{
+		triggered,
+		streamTask("quotes", "@every 1h"),
+	}}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	if s.entries["orders"].trigger == nil || s.entries["quotes"].trigger != nil {
+		t.Fatalf("only the task with a trigger should get a debouncer")
+	}
+
+	if err := s.Reload(&config.Config{Tasks: []config.TaskConfig{streamTask("orders", "@every 1h")}}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if s.entries["orders"].trigger != nil {
+		t.Errorf("trigger should be dropped when removed from the config")
+	}
+}
+
+func TestReload_CDCTasksAreNotScheduled(t *testing.T) {
+	cdc := streamTask("orders", "")
+	cdc.CDC = &config.CDCConfig{Slot: "red_courier_orders", Publication: "red_courier", LSNKey: "lsn:orders"}
+	s, err := NewScheduler(context.Background(), &config.Config{Tasks: []config.TaskConfig{
+		cdc,
+		streamTask("quotes", "@every 1h"),
+	}}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	if _, ok := s.entries["orders"]; !ok {
+		t.Fatalf("cdc task should be registered")
+	}
+	if got := len(s.cron.Entries()); got != 1 {
+		t.Errorf("only the polled task should have a cron entry, got %d", got)
+	}
+
+	// Turning the polled task into a cdc task removes its cron entry.
+	quotes := streamTask("quotes", "")
+	quotes.CDC = &config.CDCConfig{Slot: "red_courier_quotes", Publication: "red_courier", LSNKey: "lsn:quotes"}
+	if err := s.Reload(&config.Config{Tasks: []config.TaskConfig{cdc, quotes}}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := len(s.cron.Entries()); got != 0 {
+		t.Errorf("expected no cron entries, got %d", got)
+	}
+}
+
+func TestSchedule_TimezoneAndTimeout(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	tcfg := streamTask("orders", "30 9 * * *")
+	tcfg.Timezone = "America/New_York"
+	tcfg.Timeout = "5s"
+	s, err := NewScheduler(context.Background(), &config.Config{Tasks: []config.TaskConfig{tcfg}}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	s.start(context.Background())
+	defer s.Stop()
+
+	next := s.cron.Entry(s.entries["orders"].id).Next.In(ny)
+	if next.Hour() != 9 || next.Minute() != 30 {
+		t.Errorf("next run should be 09:30 New York time, got %s", next)
+	}
+
+	var deadline time.Duration
+	s.runTask = func(ctx context.Context, tk *task.Task) error {
+		d, _ := ctx.Deadline()
+		deadline = time.Until(d)
+		return nil
+	}
+	s.run(s.entries["orders"].task, "test")
+	if deadline <= 4*time.Second || deadline > 5*time.Second {
+		t.Errorf("run deadline: got %s want about 5s", deadline)
+	}
+}
+
+func TestDelay_Jitter(t *testing.T) {
+	s, err := NewScheduler(context.Background(), &config.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	start := time.Now()
+	if !s.delay(20 * time.Millisecond) {
+		t.Fatalf("delay should report true while the scheduler runs")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("delay of up to 20ms took %s", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.start(ctx)
+	defer s.Stop()
+	if s.delay(time.Hour) {
+		t.Errorf("delay should stop when the scheduler stops")
+	}
+}
@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	listenRetryMin = time.Second
+	listenRetryMax = 30 * time.Second
+)
+
+// listener holds a dedicated Postgres connection that LISTENs on every trigger
+// channel and calls notify for each notification. The connection is opened with
+// the pool's settings but outside the pool, so its LISTEN state never leaks into
+// connections used for queries. It reconnects with backoff until stopped.
+type listener struct {
+	channels []string
+	cancel   context.CancelFunc
+}
+
+func startListener(ctx context.Context, pool *pgxpool.Pool, channels []string, notify func(channel string)) *listener {
+	ctx, cancel := context.WithCancel(ctx)
+	l := &listener{channels: channels, cancel: cancel}
+	go l.run(ctx, pool.Config().ConnConfig, notify)
+	return l
+}
+
+func (l *listener) stop() {
+	l.cancel()
+}
+
+func (l *listener) run(ctx context.Context, connCfg *pgx.ConnConfig, notify func(channel string)) {
+	retry := listenRetryMin
+	reconnecting := false
+	for ctx.Err() == nil {
+		subscribed, err := l.listen(ctx, connCfg, notify, reconnecting)
+		if ctx.Err() != nil {
+			return
+		}
+		if subscribed {
+			retry = listenRetryMin
+		}
+		log.Printf("LISTEN connection lost, retrying in %s: %v", retry, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, listenRetryMax)
+		reconnecting = true
+	}
+}
+
+// listen connects, subscribes to every channel and delivers notifications until the
+// connection fails, reporting whether it got as far as subscribing. After a reconnect
+// every channel is notified once, because notifications sent while disconnected are lost.
+func (l *listener) listen(ctx context.Context, connCfg *pgx.ConnConfig, notify func(channel string), reconnecting bool) (bool, error) {
+	conn, err := pgx.ConnectConfig(ctx, connCfg)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	for _, ch := range l.channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{ch}.Sanitize()); err != nil {
+			return false, err
+		}
+	}
+	log.Printf("Listening for notifications on %v", l.channels)
+	if reconnecting {
+		for _, ch := range l.channels {
+			notify(ch)
+		}
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		notify(n.Channel)
+	}
+}
+
+// sameChannels reports whether a listener is already subscribed to exactly channels.
+func (l *listener) sameChannels(channels []string) bool {
+	return l != nil && slices.Equal(l.channels, channels)
+}
+
+// debouncer coalesces a burst of calls to kick into a single call of fn, made once
+// no kick has come for wait, so a burst of notifications triggers one run instead
+// of one per row. A steady stream of kicks still calls fn at least every
+// maxDebounce waits.
+type debouncer struct {
+	wait time.Duration
+	fn   func()
+
+	mu    sync.Mutex
+	timer *time.Timer
+	first time.Time // first kick of the pending burst
+}
+
+// maxDebounce bounds, in multiples of wait, how long kicks can put off the call.
+const maxDebounce = 10
+
+func (d *debouncer) kick() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	if d.timer == nil {
+		d.first = now
+		d.timer = time.AfterFunc(d.wait, func() {
+			d.mu.Lock()
+			d.timer = nil
+			d.mu.Unlock()
+			d.fn()
+		})
+		return
+	}
+	if !d.timer.Stop() {
+		// The call is already being made, and runs after this kick.
+		return
+	}
+	d.timer.Reset(max(0, min(d.wait, d.first.Add(maxDebounce*d.wait).Sub(now))))
+}
+
+// stop cancels a pending call.
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
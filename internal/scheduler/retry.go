@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/leader"
+)
+
+// retryableSQLStates are the SQLSTATE classes of errors that may go away on their
+// own: connection exceptions, transaction rollbacks (serialization failures,
+// deadlocks), insufficient resources, operator intervention (shutdowns,
+// cancelled statements) and system errors.
+var retryableSQLStates = []string{"08", "40", "53", "57", "58"}
+
+// retryableRedisErrors are the prefixes of Redis replies sent while a server is
+// starting, failing over or resharding.
+var retryableRedisErrors = []string{"LOADING", "READONLY", "MASTERDOWN", "CLUSTERDOWN", "TRYAGAIN", "BUSY"}
+
+// errTimedOut marks a run that reached the task's timeout. Another attempt would
+// get the same time to do the same work, so it is not retried.
+var errTimedOut = errors.New("run timed out")
+
+// retryable reports whether a failed run may succeed if attempted again. Lost or
+// refused connections and dial or network timeouts are transient; a run that hit
+// its own timeout, and errors in the SQL, the data or the configuration, fail the
+// same way every time and are not retried.
+func retryable(err error) bool {
+	switch {
+	case errors.Is(err, errTimedOut), errors.Is(err, context.Canceled), errors.Is(err, leader.ErrFenced):
+		return false
+	case errors.Is(err, context.DeadlineExceeded), pgconn.Timeout(err), pgconn.SafeToRetry(err):
+		// Any deadline but the run's own: a dial or I/O timeout.
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		for _, class := range retryableSQLStates {
+			if strings.HasPrefix(pgErr.Code, class) {
+				return true
+			}
+		}
+		return false
+	}
+	var redisErr goredis.Error
+	if errors.As(err, &redisErr) {
+		for _, prefix := range retryableRedisErrors {
+			if strings.HasPrefix(redisErr.Error(), prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// backoff returns the wait before retry number n (1 for the first retry): the
+// initial wait doubled for each earlier retry, capped at max, with the upper
+// half randomised so replicas and tasks failing together do not retry in step.
+func backoff(n int, initial, max time.Duration) time.Duration {
+	d := initial
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	d = min(d, max)
+	if half := d / 2; half > 0 {
+		return half + rand.N(half+1)
+	}
+	return d
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	goredis "github.com/redis/go-redis/v9"
+	"red-courier/internal/leader"
+	"red-courier/internal/redis/loader"
+)
+
+// redisReply is an error reply from Redis, like those go-redis returns.
+type redisReply string
+
+func (e redisReply) Error() string { return string(e) }
+func (redisReply) RedisError()     {}
+
+var _ goredis.Error = redisReply("")
+
+func TestRetryable(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", fmt.Errorf("failed to fetch rows: %w", &pgconn.ConnectError{}), true},
+		{"reset by peer", fmt.Errorf("failed to load into Redis: %w", syscall.ECONNRESET), true},
+		{"unexpected EOF", fmt.Errorf("failed to fetch rows: %w", io.ErrUnexpectedEOF), true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"too many connections", &pgconn.PgError{Code: "53300"}, true},
+		{"dial timeout", fmt.Errorf("dial: %w", context.DeadlineExceeded), true},
+		{"read timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, true},
+		{"run timeout", fmt.Errorf("%w after 5s: %w", errTimedOut, context.DeadlineExceeded), false},
+		{"redis loading", &loader.BatchError{Err: redisReply("LOADING Redis is loading the dataset in memory")}, true},
+		{"syntax error", fmt.Errorf("failed to fetch rows: %w", &pgconn.PgError{Code: "42601"}), false},
+		{"undefined column", &pgconn.PgError{Code: "42703"}, false},
+		{"wrong type", &loader.BatchError{Err: redisReply("WRONGTYPE Operation against a key holding the wrong kind of value")}, false},
+		{"fenced", &loader.BatchError{Err: leader.ErrFenced}, false},
+		{"cancelled", context.Canceled, false},
+		{"unknown", errors.New("replace would delete 80% of members"), false},
+	} {
+		if got := retryable(c.err); got != c.want {
+			t.Errorf("%s: got %v want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for n, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if got := backoff(n+1, time.Second, 10*time.Second); got < want/2 || got > want {
+				t.Fatalf("retry %d: got %s want between %s and %s", n+1, got, want/2, want)
+			}
+		}
+	}
+}
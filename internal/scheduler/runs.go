@@ -0,0 +1,372 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"red-courier/internal/config"
+	"red-courier/internal/task"
+)
+
+// runs tracks the runs in flight so a task that is due again before its previous
+// run finished follows its overlap policy, and caps how many tasks run at once.
+// State is keyed by task name and survives reloads, so a rescheduled task does
+// not overlap a run started under its old definition.
+type runs struct {
+	mu    sync.Mutex
+	state map[string]*runState
+	slots chan struct{} // one token per run in flight, across all tasks
+}
+
+type runState struct {
+	running int        // runs in flight
+	pending *task.Task // queued run, started when the running one finishes
+	skipped int        // runs dropped because the previous run was in flight
+
+	failures    int       // consecutive failed runs
+	lastError   string    // error of the last failed run
+	open        bool      // the circuit breaker paused the task
+	pausedUntil time.Time // when a paused task is tried again
+}
+
+// resetFailures closes the circuit breaker and forgets past failures.
+func (st *runState) resetFailures() {
+	st.failures, st.lastError, st.open, st.pausedUntil = 0, "", false, time.Time{}
+}
+
+func newRuns(limit int) *runs {
+	return &runs{state: make(map[string]*runState), slots: make(chan struct{}, limit)}
+}
+
+// setLimit changes the concurrency cap. Runs in flight release their slot in the
+// old pool, so the new cap applies fully once they finish.
+func (r *runs) setLimit(limit int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cap(r.slots) != limit {
+		r.slots = make(chan struct{}, limit)
+	}
+}
+
+// begin reports whether t may start now. Otherwise the run is queued or skipped
+// according to t's overlap policy.
+func (r *runs) begin(t *task.Task, reason string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := t.Config.Name
+	st := r.state[name]
+	if st == nil {
+		st = &runState{}
+		r.state[name] = st
+	}
+
+	policy := t.Config.EffectiveOverlap()
+	switch {
+	case st.running == 0 || policy == config.OverlapAllow:
+		st.running++
+		return true
+	case policy == config.OverlapQueue && st.pending == nil:
+		st.pending = t
+		log.Printf("Task %s is still running; queued the next run (%s)", name, reason)
+		return false
+	}
+	st.skipped++
+	log.Printf("Task %s is still running; skipped run (%s), %d skipped so far", name, reason, st.skipped)
+	return false
+}
+
+// finish records that a run of the named task ended and returns the queued run
+// to start in its place, if any.
+func (r *runs) finish(name string) *task.Task {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.state[name]
+	st.running--
+	next := st.pending
+	if next != nil && st.running == 0 {
+		st.pending = nil
+		st.running++
+		return next
+	}
+	return nil
+}
+
+// replace makes a queued run of a rescheduled task use its new definition. The
+// task's failures are forgotten, since the change may well be the fix.
+func (r *runs) replace(t *task.Task) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.state[t.Config.Name]
+	if st == nil {
+		return
+	}
+	if st.pending != nil {
+		st.pending = t
+	}
+	st.resetFailures()
+}
+
+// forget drops the queued run and the failures of a removed task.
+func (r *runs) forget(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if st := r.state[name]; st != nil {
+		st.pending = nil
+		st.resetFailures()
+	}
+}
+
+// paused reports whether the named task's circuit breaker is open and the task
+// not yet due to be tried again.
+func (r *runs) paused(name string, now time.Time) (until time.Time, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if st := r.state[name]; st != nil && st.open && now.Before(st.pausedUntil) {
+		return st.pausedUntil, true
+	}
+	return time.Time{}, false
+}
+
+// succeeded records a successful run, closing the task's circuit breaker.
+func (r *runs) succeeded(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if st := r.state[name]; st != nil {
+		if st.open {
+			log.Printf("Task %s succeeded again; circuit breaker closed", name)
+		}
+		st.resetFailures()
+	}
+}
+
+// failed records a failed run of t. Once its breaker's threshold of consecutive
+// failures is reached, including by the first run after a pause, the task is
+// paused.
+func (r *runs) failed(t *task.Task, err error, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.state[t.Config.Name]
+	if st == nil {
+		return
+	}
+	st.failures++
+	st.lastError = err.Error()
+	cb := t.Config.CircuitBreaker
+	if cb == nil || st.failures < cb.EffectiveFailureThreshold() {
+		return
+	}
+	st.open = true
+	st.pausedUntil = now.Add(cb.EffectivePause())
+	log.Printf("Task %s failed %d runs in a row; circuit breaker paused it until %s", t.Config.Name, st.failures, st.pausedUntil.Format(time.RFC3339))
+}
+
+// health returns the failure state of the named task.
+func (r *runs) health(name string) TaskHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := TaskHealth{Name: name, Healthy: true}
+	if st := r.state[name]; st != nil {
+		h.Healthy = !st.open
+		h.ConsecutiveFailures = st.failures
+		h.LastError = st.lastError
+		h.SkippedRuns = st.skipped
+		if st.open {
+			until := st.pausedUntil
+			h.PausedUntil = &until
+		}
+	}
+	return h
+}
+
+// acquire waits for a free slot and returns the function that releases it.
+// ok is false when ctx ends first.
+func (r *runs) acquire(ctx context.Context, name string) (release func(), ok bool) {
+	r.mu.Lock()
+	slots := r.slots
+	r.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		log.Printf("Task %s is waiting for a free slot (max_concurrent_tasks %d reached)", name, cap(slots))
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	return func() { <-slots }, true
+}
+
+// TaskHealth is the failure state of a scheduled task. A task is unhealthy while
+// its circuit breaker is open, i.e. until a run succeeds again.
+type TaskHealth struct {
+	Name                string     `json:"name"`
+	Healthy             bool       `json:"healthy"`
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	PausedUntil         *time.Time `json:"paused_until,omitempty"` // set while the circuit breaker is open
+	SkippedRuns         int        `json:"skipped_runs,omitempty"` // runs dropped because the previous run was in flight
+}
+
+// Health returns the failure state of every scheduled task, ordered by name. CDC
+// tasks are not included: they reconnect on their own rather than fail runs.
+func (s *Scheduler) Health() []TaskHealth {
+	s.mu.Lock()
+	var names []string
+	for name, e := range s.entries {
+		if e.task.Config.CDC == nil {
+			names = append(names, name)
+		}
+	}
+	s.mu.Unlock()
+	slices.Sort(names)
+
+	health := make([]TaskHealth, 0, len(names))
+	for _, name := range names {
+		health = append(health, s.runs.health(name))
+	}
+	return health
+}
+
+// run executes t unless its overlap policy says otherwise, then any run that
+// was queued behind it.
+func (s *Scheduler) run(t *task.Task, reason string) {
+	s.begin()
+	defer s.done()
+	if !s.runs.begin(t, reason) {
+		return
+	}
+	for t != nil {
+		s.execute(t, reason)
+		t = s.runs.finish(t.Config.Name)
+		reason = "queued"
+	}
+}
+
+// execute runs t, retrying transient failures as its retry policy allows, and
+// records the outcome for its circuit breaker. The concurrency slot is given up
+// while waiting to retry; a task lock is kept so no other replica starts the task.
+func (s *Scheduler) execute(t *task.Task, reason string) {
+	name := t.Config.Name
+	if until, ok := s.runs.paused(name, time.Now()); ok {
+		log.Printf("Task %s is paused by its circuit breaker until %s; skipped run (%s)", name, until.Format(time.RFC3339), reason)
+		return
+	}
+
+	parent := s.runContext()
+	release, ok := s.runs.acquire(parent, name)
+	if !ok {
+		return
+	}
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
+	if s.locker != nil {
+		lock, lockCtx, err := s.locker.TryLock(parent, name)
+		switch {
+		case err != nil:
+			log.Printf("Error in task %s: %v", name, err)
+			return
+		case lock == nil:
+			log.Printf("Task %s is running on another replica; skipped run (%s)", name, reason)
+			return
+		}
+		if strings.HasPrefix(reason, scheduledReason) {
+			claimed, err := lock.Claimed(parent)
+			if err != nil || claimed {
+				lock.Unlock(0)
+				if err != nil {
+					log.Printf("Error in task %s: %v", name, err)
+				} else {
+					log.Printf("Task %s already ran this interval on another replica; skipped run (%s)", name, reason)
+				}
+				return
+			}
+		}
+		defer func() { lock.Unlock(s.claim(t, reason)) }()
+		parent = lockCtx
+	}
+
+	attempts := 1
+	if r := t.Config.Retry; r != nil {
+		attempts = r.EffectiveMaxAttempts()
+	}
+	for attempt := 1; ; attempt++ {
+		err := s.attempt(parent, t, reason)
+		if err == nil {
+			s.runs.succeeded(name)
+			return
+		}
+		log.Printf("Error in task %s: %v", name, err)
+		if parent.Err() != nil {
+			return
+		}
+		if attempt >= attempts || !retryable(err) {
+			s.runs.failed(t, err, time.Now())
+			return
+		}
+
+		wait := backoff(attempt, t.Config.Retry.EffectiveInitialBackoff(), t.Config.Retry.EffectiveMaxBackoff())
+		log.Printf("Retrying task %s in %s (attempt %d of %d)", name, wait.Round(time.Millisecond), attempt+1, attempts)
+		release()
+		release = nil
+		select {
+		case <-time.After(wait):
+		case <-parent.Done():
+			return
+		}
+		if release, ok = s.runs.acquire(parent, name); !ok {
+			return
+		}
+	}
+}
+
+// attempt runs t once under the task's timeout. A run that fails once the timeout
+// has passed fails with errTimedOut.
+func (s *Scheduler) attempt(parent context.Context, t *task.Task, reason string) error {
+	timeout := t.Config.EffectiveTimeout()
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	log.Printf("Running task %s (%s)", t.Config.Name, reason)
+	err := s.runTask(ctx, t)
+	if err != nil && parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %w", errTimedOut, timeout, err)
+	}
+	return err
+}
+
+// claimMargin is how long before a task is next due its interval claim expires.
+const claimMargin = time.Second
+
+// claim returns how long a scheduled run marks its interval as claimed once it
+// finished: until shortly before the task is next due. Scheduled runs on replicas
+// whose schedule fires a little later, or on their own @every cadence, then skip
+// the interval instead of running it twice. Triggered and queued runs neither
+// claim nor check the interval; they only take the lock.
+func (s *Scheduler) claim(t *task.Task, reason string) time.Duration {
+	if !strings.HasPrefix(reason, scheduledReason) {
+		return 0
+	}
+	s.mu.Lock()
+	e := s.entries[t.Config.Name]
+	s.mu.Unlock()
+	if e == nil || e.task != t {
+		return 0
+	}
+	next := s.cron.Entry(e.id).Next
+	if next.IsZero() {
+		return 0
+	}
+	return time.Until(next) - claimMargin
+}
@@ -0,0 +1,312 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+	"red-courier/internal/config"
+	"red-courier/internal/leader"
+	"red-courier/internal/redis"
+	"red-courier/internal/task"
+)
+
+// blockingRuns makes s's runs wait on release and counts them.
+func blockingRuns(s *Scheduler) (release chan struct{}, started, maxInFlight *atomic.Int32) {
+	release = make(chan struct{})
+	started, maxInFlight = &atomic.Int32{}, &atomic.Int32{}
+	var inFlight atomic.Int32
+	s.runTask = func(ctx context.Context, t *task.Task) error {
+		started.Add(1)
+		n := inFlight.Add(1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		<-release
+		inFlight.Add(-1)
+		return nil
+	}
+	return release, started, maxInFlight
+}
+
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRun_OverlapPolicies(t *testing.T) {
+	for _, c := range []struct {
+		overlap string
+		want    int32 // runs started for three overlapping triggers
+		skipped int
+	}{
+		{config.OverlapSkip, 1, 2},
+		{config.OverlapQueue, 2, 1},
+		{config.OverlapAllow, 3, 0},
+	} {
+		tcfg := streamTask("orders", "@every 1h")
+		tcfg.Overlap = c.overlap
+		s, err := NewScheduler(context.Background(), &config.Config{Tasks: []config.TaskConfig{tcfg}}, nil, nil)
+		if err != nil {
+			t.Fatalf("NewScheduler: %v", err)
+		}
+		release, started, _ := blockingRuns(s)
+		tk := s.entries["orders"].task
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() { defer wg.Done(); s.run(tk, "test") }()
+		waitUntil(t, func() bool { return started.Load() == 1 })
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() { defer wg.Done(); s.run(tk, "test") }()
+		}
+		waitUntil(t, func() bool {
+			return s.Health()[0].SkippedRuns == c.skipped && (c.overlap != config.OverlapAllow || started.Load() == 3)
+		})
+		close(release)
+		wg.Wait()
+
+		if got := started.Load(); got != c.want {
+			t.Errorf("%s: got %d runs want %d", c.overlap, got, c.want)
+		}
+		if got := s.Health()[0].SkippedRuns; got != c.skipped {
+			t.Errorf("%s: got %d skipped runs want %d", c.overlap, got, c.skipped)
+		}
+	}
+}
+
+func TestRun_MaxConcurrentTasks(t *testing.T) {
+	cfg := &config.Config{Scheduler: config.SchedulerConfig{MaxConcurrentTasks: 2}}
+	for _, name := range []string{"orders", "quotes", "trades", "fills"} {
+		cfg.Tasks = append(cfg.Tasks, streamTask(name, "@every 1h"))
+	}
+	s, err := NewScheduler(context.Background(), cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	release, started, maxInFlight := blockingRuns(s)
+
+	var wg sync.WaitGroup
+	for _, e := range s.entries {
+		wg.Add(1)
+		go func(tk *task.Task) { defer wg.Done(); s.run(tk, "test") }(e.task)
+	}
+	waitUntil(t, func() bool { return started.Load() == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if got := started.Load(); got != 2 {
+		t.Errorf("only 2 tasks should run at once, %d started", got)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := started.Load(); got != 4 {
+		t.Errorf("every task should run eventually, got %d", got)
+	}
+	if got := maxInFlight.Load(); got > 2 {
+		t.Errorf("at most 2 tasks should run at once, saw %d", got)
+	}
+}
+
+func TestRun_TaskLocks(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := &config.Config{
+		Tasks:     []config.TaskConfig{streamTask("orders", "@every 1h")},
+		Scheduler: config.SchedulerConfig{TaskLocks: &config.TaskLocksConfig{Enabled: true}},
+	}
+	// Two replicas with the same config and Redis.
+	replica := func(id string) (*Scheduler, chan struct{}, *atomic.Int32) {
+		r := redis.NewRedisClient(redis.RedisConfig{Addr: mr.Addr()})
+		t.Cleanup(func() { _ = r.Close() })
+		cfg.Scheduler.TaskLocks.ID = id
+		s, err := NewScheduler(context.Background(), cfg, nil, r)
+		if err != nil {
+			t.Fatalf("NewScheduler: %v", err)
+		}
+		release, started, _ := blockingRuns(s)
+		return s, release, started
+	}
+	a, releaseA, startedA := replica("a")
+	b, releaseB, startedB := replica("b")
+	close(releaseB)
+	var fenced atomic.Bool
+	run := a.runTask
+	a.runTask = func(ctx context.Context, tk *task.Task) error {
+		_, ok := leader.FenceFrom(ctx)
+		fenced.Store(ok)
+		return run(ctx, tk)
+	}
+
+	done := make(chan struct{})
+	go func() { defer close(done); a.run(a.entries["orders"].task, "notify: orders") }()
+	waitUntil(t, func() bool { return startedA.Load() == 1 })
+	b.run(b.entries["orders"].task, "notify: orders")
+	if startedB.Load() != 0 {
+		t.Fatalf("a task must not run on two replicas at once")
+	}
+	close(releaseA)
+	<-done
+	if !fenced.Load() {
+		t.Errorf("a locked run should carry the lock's fence")
+	}
+
+	// A triggered run gives the lock up when it ends.
+	b.run(b.entries["orders"].task, "notify: orders")
+	if startedB.Load() != 1 {
+		t.Fatalf("b should run once a has finished, got %d runs", startedB.Load())
+	}
+
+	// A scheduled run claims its interval until shortly before the task is next
+	// due, so the other replica's schedule does not run the task again meanwhile.
+	a.start(context.Background())
+	defer a.Stop()
+	a.run(a.entries["orders"].task, scheduledReason+"@every 1h")
+	if startedA.Load() != 2 {
+		t.Fatalf("scheduled run on a: got %d runs want 2", startedA.Load())
+	}
+	if mr.Exists(config.DefaultLockKeyPrefix + "orders") {
+		t.Errorf("a scheduled run should release the lock when it ends")
+	}
+	if ttl := mr.TTL(config.DefaultLockKeyPrefix + "orders:claimed"); ttl < 50*time.Minute || ttl > time.Hour {
+		t.Errorf("interval claim ttl: got %s want just under 1h", ttl)
+	}
+	b.run(b.entries["orders"].task, scheduledReason+"@every 1h")
+	if startedB.Load() != 1 {
+		t.Errorf("b should skip the interval a already ran")
+	}
+	// Triggered runs are not held back by the claim.
+	b.run(b.entries["orders"].task, "notify: orders")
+	if startedB.Load() != 2 {
+		t.Errorf("a triggered run should run in a claimed interval, got %d runs", startedB.Load())
+	}
+}
+
+func TestRun_RetriesAndCircuitBreaker(t *testing.T) {
+	tcfg := streamTask("orders", "@every 1h")
+	tcfg.Retry = &config.RetryConfig{MaxAttempts: 3, InitialBackoff: "1ms", MaxBackoff: "2ms"}
+	tcfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 2, Pause: "1h"}
+	s, err := NewScheduler(context.Background(), &config.Config{Tasks: []config.TaskConfig{tcfg}}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	var calls, failFirst atomic.Int32
+	var fail atomic.Value
+	s.runTask = func(ctx context.Context, tk *task.Task) error {
+		n := calls.Add(1)
+		if n <= failFirst.Load() {
+			return fail.Load().(error)
+		}
+		return nil
+	}
+	tk := s.entries["orders"].task
+	health := func() TaskHealth { return s.Health()[0] }
+
+	// A transient failure is retried within the same run.
+	failFirst.Store(2)
+	fail.Store(fmt.Errorf("failed to fetch rows: %w", syscall.ECONNREFUSED))
+	s.run(tk, "test")
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("transient failure: got %d attempts want 3", got)
+	}
+	if h := health(); !h.Healthy || h.ConsecutiveFailures != 0 {
+		t.Errorf("a run that succeeded on retry is healthy, got %+v", h)
+	}
+
+	// An SQL error is not retried, and two failed runs open the breaker.
+	calls.Store(0)
+	failFirst.Store(100)
+	fail.Store(fmt.Errorf("failed to fetch rows: %w", &pgconn.PgError{Code: "42703", Message: "column \"x\" does not exist"}))
+	s.run(tk, "test")
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("SQL error: got %d attempts want 1", got)
+	}
+	if h := health(); !h.Healthy || h.ConsecutiveFailures != 1 || h.LastError == "" {
+		t.Errorf("after one failed run: got %+v", h)
+	}
+	s.run(tk, "test")
+	h := health()
+	if h.Healthy || h.ConsecutiveFailures != 2 || h.PausedUntil == nil {
+		t.Fatalf("breaker should be open after two failed runs, got %+v", h)
+	}
+
+	// While paused the task does not run at all.
+	s.run(tk, "test")
+	if got := calls.Load(); got != 2 {
+		t.Errorf("paused task ran: %d attempts", got)
+	}
+
+	// Once the pause is over, a successful run closes the breaker.
+	s.runs.state["orders"].pausedUntil = time.Now().Add(-time.Second)
+	failFirst.Store(0)
+	s.run(tk, "test")
+	if h := health(); !h.Healthy || h.ConsecutiveFailures != 0 || h.PausedUntil != nil {
+		t.Errorf("breaker should close after a successful run, got %+v", h)
+	}
+}
+
+func TestRun_TimeoutIsNotRetried(t *testing.T) {
+	tcfg := streamTask("orders", "@every 1h")
+	tcfg.Timeout = "20ms"
+	tcfg.Retry = &config.RetryConfig{MaxAttempts: 3, InitialBackoff: "1ms", MaxBackoff: "2ms"}
+	s, err := NewScheduler(context.Background(), &config.Config{Tasks: []config.TaskConfig{tcfg}}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	var calls atomic.Int32
+	s.runTask = func(ctx context.Context, tk *task.Task) error {
+		calls.Add(1)
+		<-ctx.Done()
+		return fmt.Errorf("failed to fetch rows: %w", ctx.Err())
+	}
+	s.run(s.entries["orders"].task, "test")
+	if got := calls.Load(); got != 1 {
+		t.Errorf("a run that hit its timeout was attempted %d times, want 1", got)
+	}
+	if h := s.Health()[0]; !strings.Contains(h.LastError, "run timed out after 20ms") {
+		t.Errorf("last error: got %q", h.LastError)
+	}
+}
+
+func TestLead_WaitsForRunsInFlight(t *testing.T) {
+	s, err := NewScheduler(context.Background(), &config.Config{Tasks: []config.TaskConfig{streamTask("orders", "@every 1h")}}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	release, started, _ := blockingRuns(s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	led := make(chan struct{})
+	go func() { defer close(led); s.Lead(ctx) }()
+	go s.run(s.entries["orders"].task, "notify: orders")
+	waitUntil(t, func() bool { return started.Load() == 1 })
+
+	// Losing the lease must not end Lead while the run is still writing.
+	cancel()
+	select {
+	case <-led:
+		t.Fatalf("Lead returned with a run in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-led:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Lead should return once the run finished")
+	}
+}
@@ -7,11 +7,34 @@ import (
 )
 
 type TrackingSpec struct {
-	Column       string // resolved db column name
-	Operator     string // e.g. ">" or ">="
+	Column       string   // resolved db column name
+	Columns      []string // resolved composite cursor columns; takes precedence over Column
+	Operator     string   // e.g. ">" or ">="
 	LastValueKey string
 }
 
+// CursorColumns returns Columns, or Column on its own.
+func (t TrackingSpec) CursorColumns() []string {
+	if len(t.Columns) > 0 {
+		return t.Columns
+	}
+	if t.Column == "" {
+		return nil
+	}
+	return []string{t.Column}
+}
+
+// Direction returns the ORDER BY direction that walks rows in checkpoint order:
+// ascending for ">"/">=" and descending for "<"/"<=". NULLs sort last either way
+// (Postgres puts them first in descending order), after every row a checkpoint
+// can move to.
+func (t TrackingSpec) Direction() string {
+	if strings.HasPrefix(t.Operator, "<") {
+		return "DESC NULLS LAST"
+	}
+	return "ASC"
+}
+
 // Input for building a SELECT query.
 type SelectSpec struct {
 	Schema    string   // defaults to "public" if empty
@@ -20,14 +43,27 @@ type SelectSpec struct {
 	Where     string   // optional raw sql (without "WHERE")
 	Tracking  *TrackingSpec
 	LastValue *string // optional; if nil/"" => first run
+	LastTuple []any   // typed cursor values, one per tracking column; used instead of LastValue
+	Limit     int     // optional; > 0 appends LIMIT for keyset paging
+}
+
+func (s SelectSpec) cursor() []any {
+	if len(s.LastTuple) > 0 {
+		return s.LastTuple
+	}
+	if s.LastValue == nil || *s.LastValue == "" {
+		return nil
+	}
+	return []any{*s.LastValue}
 }
 
 // Output plan for DB execution.
 type SelectPlan struct {
-	SQL         string
-	Args        []any
-	FirstRun    bool   // true when no last checkpoint (i.e., we didn't add tracking predicate)
-	TrackingCol string // resolved tracking column if present
+	SQL          string
+	Args         []any
+	FirstRun     bool     // true when no last checkpoint (i.e., we didn't add tracking predicate)
+	TrackingCol  string   // resolved tracking column if present (first column of a composite cursor)
+	TrackingCols []string // all resolved tracking columns
 }
 
 // Parse "schema.table" or "table" into (schema, table).
@@ -40,9 +76,21 @@ func SplitSchemaTable(qualified string) (string, string) {
 	return "public", qualified
 }
 
+// PgName returns the name Postgres stores for an identifier written in SQL:
+// quoted identifiers keep their case, unquoted ones are folded to lower case.
+func PgName(ident string) string {
+	if len(ident) >= 2 && strings.HasPrefix(ident, `"`) && strings.HasSuffix(ident, `"`) {
+		return strings.ReplaceAll(ident[1:len(ident)-1], `""`, `"`)
+	}
+	return strings.ToLower(ident)
+}
+
 // Build a SELECT plan with optional static WHERE and optional tracking clause.
 // - If LastValue is nil/empty and Tracking != nil => FirstRun=true and no tracking predicate is appended.
 // - If LastValue is present => append tracking predicate with positional arg $N.
+// - With composite tracking Columns, LastTuple is compared as a row value: (a, b) > ($1, $2).
+// - If Tracking != nil => rows are ordered by the tracking column.
+// - If Limit > 0 => LIMIT is appended, which with the ordering gives keyset paging.
 func BuildSelect(spec SelectSpec) (SelectPlan, error) {
 	if len(spec.Columns) == 0 {
 		return SelectPlan{}, fmt.Errorf("no columns provided")
@@ -74,19 +122,34 @@ func BuildSelect(spec SelectSpec) (SelectPlan, error) {
 	}
 
 	// tracking
+	var trackingCols []string
 	if spec.Tracking != nil {
-		trackingCol = spec.Tracking.Column
-		if trackingCol == "" {
+		trackingCols = spec.Tracking.CursorColumns()
+		if len(trackingCols) == 0 {
 			return SelectPlan{}, fmt.Errorf("tracking column is empty")
 		}
-		if spec.LastValue == nil || *spec.LastValue == "" {
+		trackingCol = trackingCols[0]
+		cursor := spec.cursor()
+		switch {
+		case len(cursor) == 0:
 			// No checkpoint -> FIRST RUN -> do not add tracking predicate
 			firstRun = true
-		} else {
+		case len(cursor) != len(trackingCols):
+			return SelectPlan{}, fmt.Errorf("tracking cursor has %d values for %d columns", len(cursor), len(trackingCols))
+		case len(trackingCols) == 1:
 			// Add param with correct index
 			paramIdx := len(args) + 1
 			clauses = append(clauses, fmt.Sprintf("%s %s $%d", trackingCol, spec.Tracking.Operator, paramIdx))
-			args = append(args, *spec.LastValue)
+			args = append(args, cursor[0])
+		default:
+			// Row-value comparison: (a, b) > ($1, $2)
+			params := make([]string, len(cursor))
+			for i, v := range cursor {
+				args = append(args, v)
+				params[i] = fmt.Sprintf("$%d", len(args))
+			}
+			clauses = append(clauses, fmt.Sprintf("(%s) %s (%s)",
+				strings.Join(trackingCols, ", "), spec.Tracking.Operator, strings.Join(params, ", ")))
 		}
 	}
 
@@ -94,11 +157,25 @@ func BuildSelect(spec SelectSpec) (SelectPlan, error) {
 		sql += " WHERE " + strings.Join(clauses, " AND ")
 	}
 
+	// Order by the tracking columns so rows are loaded, and checkpointed, in cursor order.
+	if spec.Tracking != nil {
+		order := make([]string, len(trackingCols))
+		for i, c := range trackingCols {
+			order[i] = c + " " + spec.Tracking.Direction()
+		}
+		sql += " ORDER BY " + strings.Join(order, ", ")
+	}
+
+	if spec.Limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", spec.Limit)
+	}
+
 	return SelectPlan{
-		SQL:         sql,
-		Args:        args,
-		FirstRun:    firstRun,
-		TrackingCol: trackingCol,
+		SQL:          sql,
+		Args:         args,
+		FirstRun:     firstRun,
+		TrackingCol:  trackingCol,
+		TrackingCols: trackingCols,
 	}, nil
 }
 
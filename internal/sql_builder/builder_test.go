@@ -57,7 +57,7 @@ func TestBuild_WithTracking_FirstRun(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	want := `SELECT id, created_at FROM "public"."orders"`
+	want := `SELECT id, created_at FROM "public"."orders" ORDER BY created_at ASC`
 	if plan.SQL != want || !plan.FirstRun {
 		t.Fatalf("unexpected plan: %+v", plan)
 	}
@@ -80,7 +80,7 @@ func TestBuild_WithWhere_And_Tracking(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	wantSQL := `SELECT id, amount, created_at FROM "public"."orders" WHERE amount > 1000 AND created_at > $1`
+	wantSQL := `SELECT id, amount, created_at FROM "public"."orders" WHERE amount > 1000 AND created_at > $1 ORDER BY created_at ASC`
 	if plan.SQL != wantSQL {
 		t.Fatalf("sql mismatch:\n got: %s\nwant: %s", plan.SQL, wantSQL)
 	}
@@ -91,3 +91,97 @@ func TestBuild_WithWhere_And_Tracking(t *testing.T) {
 		t.Fatalf("expected FirstRun=false")
 	}
 }
+
+func TestBuild_WithDescendingTracking_OrdersDesc(t *testing.T) {
+	last := "100"
+	spec := SelectSpec{
+		Schema:  "public",
+		Table:   "orders",
+		Columns: []string{"id"},
+		Tracking: &TrackingSpec{
+			Column:   "id",
+			Operator: "<",
+		},
+		LastValue: &last,
+	}
+	plan, err := BuildSelect(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT id FROM "public"."orders" WHERE id < $1 ORDER BY id DESC NULLS LAST`
+	if plan.SQL != want {
+		t.Fatalf("sql mismatch:\n got: %s\nwant: %s", plan.SQL, want)
+	}
+}
+
+func TestBuild_KeysetPage(t *testing.T) {
+	last := "2025-09-18T00:00:00Z"
+	spec := SelectSpec{
+		Schema:  "public",
+		Table:   "orders",
+		Columns: []string{"id", "updated_at"},
+		Where:   "status = 'NEW'",
+		Tracking: &TrackingSpec{
+			Column:   "updated_at",
+			Operator: ">",
+		},
+		LastValue: &last,
+		Limit:     1000,
+	}
+	plan, err := BuildSelect(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT id, updated_at FROM "public"."orders" WHERE status = 'NEW' AND updated_at > $1 ORDER BY updated_at ASC LIMIT 1000`
+	if plan.SQL != want {
+		t.Fatalf("sql mismatch:\n got: %s\nwant: %s", plan.SQL, want)
+	}
+}
+
+func TestBuild_CompositeCursor(t *testing.T) {
+	spec := SelectSpec{
+		Schema:  "public",
+		Table:   "orders",
+		Columns: []string{"id", "updated_at"},
+		Where:   "status = 'NEW'",
+		Tracking: &TrackingSpec{
+			Columns:  []string{"updated_at", "id"},
+			Operator: ">",
+		},
+		LastTuple: []any{"2025-09-18T00:00:00Z", "42"},
+		Limit:     100,
+	}
+	plan, err := BuildSelect(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT id, updated_at FROM "public"."orders" WHERE status = 'NEW' AND (updated_at, id) > ($1, $2) ORDER BY updated_at ASC, id ASC LIMIT 100`
+	if plan.SQL != want {
+		t.Fatalf("sql mismatch:\n got: %s\nwant: %s", plan.SQL, want)
+	}
+	if !reflect.DeepEqual(plan.Args, []any{"2025-09-18T00:00:00Z", "42"}) {
+		t.Fatalf("args mismatch: %+v", plan.Args)
+	}
+
+	spec.LastTuple = nil
+	plan, err = BuildSelect(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.FirstRun || len(plan.Args) != 0 {
+		t.Fatalf("expected first run without args, got %+v", plan)
+	}
+
+	spec.LastTuple = []any{"only-one"}
+	if _, err := BuildSelect(spec); err == nil {
+		t.Fatalf("expected error for cursor arity mismatch")
+	}
+}
+
+func TestPgName(t *testing.T) {
+	for in, want := range map[string]string{"updated_at": "updated_at", "UpdatedAt": "updatedat", `"UpdatedAt"`: "UpdatedAt", `"a""b"`: `a"b`} {
+		if got := PgName(in); got != want {
+			t.Errorf("PgName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package sqlbuilder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LastValuePlaceholder marks where a custom query compares against the tracking cursor.
+const LastValuePlaceholder = ":last_value"
+
+// Input for planning a task's custom SELECT.
+type QuerySpec struct {
+	SQL       string // REQUIRED full SELECT, optionally using :last_value
+	Tracking  *TrackingSpec
+	LastTuple []any // typed cursor values, one per tracking column; nil => first run
+}
+
+// BuildQuery plans a custom query.
+//   - Every :last_value becomes $1 (or ($1, $2, ...) for a composite cursor). On the first
+//     run the parameters are NULL, so queries should be written as
+//     "(:last_value IS NULL OR updated_at > :last_value)".
+//   - With tracking the query is wrapped so its rows come back in cursor order:
+//     SELECT * FROM (<query>) AS q ORDER BY <tracking columns>.
+func BuildQuery(spec QuerySpec) (SelectPlan, error) {
+	query := strings.TrimSpace(spec.SQL)
+	query = strings.TrimSpace(strings.TrimSuffix(query, ";"))
+	if query == "" {
+		return SelectPlan{}, fmt.Errorf("query is empty")
+	}
+
+	at := placeholders(query)
+	usesCursor := len(at) > 0
+	if spec.Tracking == nil {
+		if usesCursor {
+			return SelectPlan{}, fmt.Errorf("query uses %s but the task has no tracking", LastValuePlaceholder)
+		}
+		return SelectPlan{SQL: query}, nil
+	}
+	if !usesCursor {
+		return SelectPlan{}, fmt.Errorf("query must compare against %s when tracking is configured", LastValuePlaceholder)
+	}
+
+	cols := spec.Tracking.CursorColumns()
+	if len(cols) == 0 {
+		return SelectPlan{}, fmt.Errorf("tracking column is empty")
+	}
+	args := make([]any, len(cols))
+	params := make([]string, len(cols))
+	switch {
+	case len(spec.LastTuple) == 0:
+		// FIRST RUN: bind NULLs; the query's "IS NULL" guard selects everything.
+	case len(spec.LastTuple) != len(cols):
+		return SelectPlan{}, fmt.Errorf("tracking cursor has %d values for %d columns", len(spec.LastTuple), len(cols))
+	default:
+		copy(args, spec.LastTuple)
+	}
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+	param := params[0]
+	if len(params) > 1 {
+		param = "(" + strings.Join(params, ", ") + ")"
+	}
+	var b strings.Builder
+	prev := 0
+	for _, i := range at {
+		b.WriteString(query[prev:i])
+		b.WriteString(param)
+		prev = i + len(LastValuePlaceholder)
+	}
+	b.WriteString(query[prev:])
+	query = b.String()
+
+	order := make([]string, len(cols))
+	for i, c := range cols {
+		order[i] = "q." + c + " " + spec.Tracking.Direction()
+	}
+	return SelectPlan{
+		SQL:          fmt.Sprintf("SELECT * FROM (%s) AS q ORDER BY %s", query, strings.Join(order, ", ")),
+		Args:         args,
+		FirstRun:     len(spec.LastTuple) == 0,
+		TrackingCol:  cols[0],
+		TrackingCols: cols,
+	}, nil
+}
+
+// placeholders returns the offsets of each :last_value in query. Text inside string
+// literals, quoted identifiers, dollar-quoted strings and comments is skipped, as
+// are "::last_value" casts and longer names such as ":last_value_at".
+func placeholders(query string) []int {
+	var at []int
+	for i := 0; i < len(query); {
+		rest := query[i:]
+		switch {
+		case rest[0] == '\'':
+			// E'...' strings may also escape a quote with a backslash.
+			escapes := i > 0 && (query[i-1] == 'E' || query[i-1] == 'e') && (i < 2 || !isIdentChar(query[i-2]))
+			i += quotedLen(rest, '\'', escapes)
+		case rest[0] == '"':
+			i += quotedLen(rest, '"', false)
+		case strings.HasPrefix(rest, "--"):
+			if n := strings.IndexByte(rest, '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(rest, "/*"):
+			i += blockCommentLen(rest)
+		case rest[0] == '$':
+			if tag := dollarTag(rest); tag != "" {
+				if n := strings.Index(rest[len(tag):], tag); n >= 0 {
+					i += n + 2*len(tag)
+				} else {
+					i = len(query)
+				}
+			} else {
+				i++
+			}
+		case strings.HasPrefix(rest, "::"):
+			i += 2
+		case strings.HasPrefix(rest, LastValuePlaceholder):
+			end := len(LastValuePlaceholder)
+			if end == len(rest) || !isIdentChar(rest[end]) {
+				at = append(at, i)
+			}
+			i += end
+		default:
+			i++
+		}
+	}
+	return at
+}
+
+// quotedLen returns the length of the quoted text s starts with, including both
+// quotes; a doubled quote (or, with escapes, a backslash) does not end it.
+func quotedLen(s string, quote byte, escapes bool) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case escapes && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// blockCommentLen returns the length of the /* comment */ s starts with. Postgres
+// block comments nest.
+func blockCommentLen(s string) int {
+	depth := 0
+	for i := 0; i+1 < len(s); i++ {
+		switch s[i : i+2] {
+		case "/*":
+			depth++
+			i++
+		case "*/":
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// dollarTag returns the $tag$ that opens a dollar-quoted string at the start of s,
+// or "" when s starts with something else, such as a $1 parameter.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case !isIdentChar(c) || (i == 1 && c >= '0' && c <= '9'):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
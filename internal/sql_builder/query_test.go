@@ -0,0 +1,104 @@
+// internal/sqlbuilder/query_test.go
+package sqlbuilder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildQuery_NoTracking(t *testing.T) {
+	plan, err := BuildQuery(QuerySpec{SQL: "SELECT instrument, max(px) AS px FROM quotes GROUP BY instrument;\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT instrument, max(px) AS px FROM quotes GROUP BY instrument`
+	if plan.SQL != want || len(plan.Args) != 0 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+}
+
+func TestBuildQuery_Tracking(t *testing.T) {
+	spec := QuerySpec{
+		SQL: `SELECT o.id, o.updated_at, c.name FROM orders o JOIN customers c ON c.id = o.customer_id
+WHERE (:last_value IS NULL OR o.updated_at > :last_value)`,
+		Tracking: &TrackingSpec{Column: "updated_at", Operator: ">"},
+	}
+	plan, err := BuildQuery(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT * FROM (SELECT o.id, o.updated_at, c.name FROM orders o JOIN customers c ON c.id = o.customer_id
+WHERE ($1 IS NULL OR o.updated_at > $1)) AS q ORDER BY q.updated_at ASC`
+	if plan.SQL != want {
+		t.Fatalf("sql mismatch:\n got: %s\nwant: %s", plan.SQL, want)
+	}
+	if !plan.FirstRun || !reflect.DeepEqual(plan.Args, []any{nil}) {
+		t.Fatalf("first run should bind NULL: %+v", plan)
+	}
+
+	spec.LastTuple = []any{"2025-09-18T00:00:00Z"}
+	plan, err = BuildQuery(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.FirstRun || !reflect.DeepEqual(plan.Args, []any{"2025-09-18T00:00:00Z"}) {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+}
+
+func TestBuildQuery_CompositeCursor(t *testing.T) {
+	plan, err := BuildQuery(QuerySpec{
+		SQL:       `SELECT id, updated_at FROM orders WHERE (:last_value IS NULL OR (updated_at, id) < :last_value)`,
+		Tracking:  &TrackingSpec{Columns: []string{"updated_at", "id"}, Operator: "<"},
+		LastTuple: []any{"2025-09-18T00:00:00Z", "42"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT * FROM (SELECT id, updated_at FROM orders WHERE (($1, $2) IS NULL OR (updated_at, id) < ($1, $2))) AS q ORDER BY q.updated_at DESC NULLS LAST, q.id DESC NULLS LAST`
+	if plan.SQL != want {
+		t.Fatalf("sql mismatch:\n got: %s\nwant: %s", plan.SQL, want)
+	}
+}
+
+func TestBuildQuery_PlaceholderRules(t *testing.T) {
+	tracking := &TrackingSpec{Column: "updated_at", Operator: ">"}
+	cases := []struct {
+		name string
+		spec QuerySpec
+	}{
+		{"empty", QuerySpec{SQL: " ; "}},
+		{"placeholder without tracking", QuerySpec{SQL: "SELECT * FROM t WHERE ts > :last_value"}},
+		{"tracking without placeholder", QuerySpec{SQL: "SELECT * FROM t", Tracking: tracking}},
+		{"only a longer name", QuerySpec{SQL: "SELECT * FROM t WHERE ts > :last_value_at", Tracking: tracking}},
+		{"only in a comment", QuerySpec{SQL: "SELECT * FROM t -- WHERE ts > :last_value\nWHERE true", Tracking: tracking}},
+		{"only in a string", QuerySpec{SQL: "SELECT ':last_value' AS note FROM t", Tracking: tracking}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := BuildQuery(tc.spec); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestBuildQuery_SkipsQuotedAndCommentedPlaceholders(t *testing.T) {
+	plan, err := BuildQuery(QuerySpec{
+		SQL: `SELECT id, ':last_value' AS a, E'it\'s :last_value' AS b, $tag$ :last_value $tag$ AS c, "x:last_value" AS d
+FROM t /* outer /* :last_value */ still :last_value */
+WHERE (:last_value::timestamptz IS NULL OR ts > :last_value) -- :last_value
+  AND label <> 'don''t :last_value'`,
+		Tracking: &TrackingSpec{Column: "ts", Operator: ">"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT * FROM (SELECT id, ':last_value' AS a, E'it\'s :last_value' AS b, $tag$ :last_value $tag$ AS c, "x:last_value" AS d
+FROM t /* outer /* :last_value */ still :last_value */
+WHERE ($1::timestamptz IS NULL OR ts > $1) -- :last_value
+  AND label <> 'don''t :last_value') AS q ORDER BY q.ts ASC`
+	if plan.SQL != want {
+		t.Fatalf("sql mismatch:\n got: %s\nwant: %s", plan.SQL, want)
+	}
+}
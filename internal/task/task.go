@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
 
+	"red-courier/internal/cdc"
 	"red-courier/internal/config"
 	"red-courier/internal/db"
+	"red-courier/internal/pgvalue"
 	"red-courier/internal/redis"
 	"red-courier/internal/redis/loader"
+	"red-courier/internal/rowstream"
 )
 
 type Task struct {
@@ -34,94 +36,159 @@ func NewTask(cfg config.TaskConfig, dbConn *db.Database, redisConn *redis.RedisC
 	}, nil
 }
 
+// Stream applies the task's changes from its replication slot until ctx is
+// cancelled. It is used instead of Run for tasks with cdc settings.
+func (t *Task) Stream(ctx context.Context) error {
+	if t.Config.CDC == nil {
+		return fmt.Errorf("task %s has no cdc settings", t.Config.Name)
+	}
+	return cdc.Run(ctx, &t.DB.Pool.Config().ConnConfig.Config, t.Config, t.RedisClient)
+}
+
 func (t *Task) Run(ctx context.Context) error {
 	log.Printf("[task:%s] Running task", t.Config.Name)
 
-	rows, err := t.DB.FetchRows(ctx, t.Config, t.RedisClient)
+	if t.Config.PageSize > 0 && t.Config.Tracking != nil {
+		return t.runPaged(ctx)
+	}
+
+	rows, err := t.DB.StreamRows(ctx, t.Config, t.RedisClient)
 	if err != nil {
 		return fmt.Errorf("failed to fetch rows: %w", err)
 	}
+	defer rows.Close()
 
+	// Rows are written while the query is still being read. Loaders commit each batch
+	// together with its tracking checkpoint, so a failure part way through re-sends the
+	// remaining rows on the next run instead of skipping them.
 	if err := t.Loader.Load(ctx, rows, t.Config, t.RedisClient); err != nil {
+		if fetchErr := rows.Err(); fetchErr != nil {
+			return fmt.Errorf("failed to fetch rows: %w", fetchErr)
+		}
 		return fmt.Errorf("failed to load into Redis: %w", err)
 	}
 
-	if t.Config.Tracking != nil && len(rows) > 0 {
-		trackingCol := t.Config.ResolveColumn(t.Config.Tracking.Column)
-		var maxVal any
-		for _, row := range rows {
-			v := row[trackingCol]
-			if maxVal == nil || compareAny(v, maxVal) > 0 {
-				maxVal = v
+	log.Printf("[task:%s] Completed with %d rows", t.Config.Name, rows.Count())
+	return nil
+}
+
+// maxGroupPages bounds, in pages, a group of rows sharing one tracking value that
+// runPaged loads at once.
+const maxGroupPages = 10
+
+// runPaged walks the table in keyset pages of PageSize rows ordered by the tracking
+// column, loading and checkpointing each page before fetching the next, so memory
+// stays bounded and an interrupted run resumes from the last committed page.
+func (t *Task) runPaged(ctx context.Context) error {
+	cursor, err := db.ReadCheckpoint(ctx, t.Config, t.RedisClient)
+	if err != nil {
+		return err
+	}
+
+	cols := t.Config.TrackingColumns()
+	page := db.Page{Cursor: cursor, Limit: t.Config.PageSize}
+	total, pages := 0, 0
+
+	for {
+		rows, err := t.DB.FetchPage(ctx, t.Config, page)
+		if err != nil {
+			return fmt.Errorf("failed to fetch page %d: %w", pages+1, err)
+		}
+		full := len(rows) == page.Limit
+
+		if full {
+			// Rows sharing the last tracking value may continue on the next page; hold
+			// them back so the next page's strict predicate picks the group up whole.
+			keep, tail := splitTrailingGroup(rows, cols)
+			switch {
+			case tail[0][cols[0]] == nil:
+				// Only NULL tracking values remain, which incremental syncs never select.
+				rows, full = keep, false
+			case len(keep) == 0:
+				// The whole page shares one value: fetch that group in full, up to
+				// maxGroupPages pages, as there is no further key to page it by.
+				v, err := cursorValues(tail[0], cols)
+				if err != nil {
+					return err
+				}
+				limit := page.Limit * maxGroupPages
+				rows, err = t.DB.FetchPage(ctx, t.Config, db.Page{Cursor: v, Operator: "=", Limit: limit + 1})
+				if err != nil {
+					return fmt.Errorf("failed to fetch page %d: %w", pages+1, err)
+				}
+				if len(rows) > limit {
+					return fmt.Errorf("page %d: more than %d rows share tracking value %v; add a unique column to tracking.columns so they can be paged", pages+1, limit, v)
+				}
+			default:
+				rows = keep
 			}
 		}
 
-		if maxStr, ok := toRedisString(maxVal); ok {
-			if err := t.RedisClient.SetString(ctx, t.Config.Tracking.LastValueKey, maxStr); err != nil {
-				log.Printf("[task:%s] Failed to persist tracking value: %v", t.Config.Name, err)
-			} else {
-				log.Printf("[task:%s] Updated checkpoint: %s = %s", t.Config.Name, t.Config.Tracking.LastValueKey, maxStr)
+		if len(rows) > 0 {
+			if err := t.Loader.Load(ctx, rowstream.FromSlice(rows), t.Config, t.RedisClient); err != nil {
+				return fmt.Errorf("failed to load page %d into Redis: %w", pages+1, err)
 			}
 		}
+		total += len(rows)
+		pages++
+
+		if !full || len(rows) == 0 {
+			break
+		}
+		last, err := cursorValues(rows[len(rows)-1], cols)
+		if err != nil {
+			return err
+		}
+		page = db.Page{Cursor: last, Operator: strictOperator(t.Config.Tracking.Operator), Limit: t.Config.PageSize}
 	}
 
-	log.Printf("[task:%s] Completed with %d rows", t.Config.Name, len(rows))
+	log.Printf("[task:%s] Completed with %d rows in %d pages", t.Config.Name, total, pages)
 	return nil
 }
 
-// TODO move to util package
-func compareAny(a, b any) int {
-	switch a := a.(type) {
-	case int64:
-		return compareInts(a, b.(int64))
-	case float64:
-		return compareFloats(a, b.(float64))
-	case string:
-		return compareStrings(a, b.(string))
-	case time.Time:
-		return compareTimes(a, b.(time.Time))
-	default:
-		return 0
+// splitTrailingGroup splits rows, ordered by cols, before the run of rows that share
+// the last row's cursor.
+func splitTrailingGroup(rows []map[string]any, cols []string) (keep, tail []map[string]any) {
+	last := rows[len(rows)-1]
+	i := len(rows) - 1
+	for i > 0 && sameCursor(rows[i-1], last, cols) {
+		i--
 	}
+	return rows[:i], rows[i:]
 }
 
-func compareInts(a, b int64) int {
-	if a > b {
-		return 1
-	} else if a < b {
-		return -1
-	} else {
-		return 0
+func sameCursor(a, b map[string]any, cols []string) bool {
+	for _, col := range cols {
+		if !sameValue(a[col], b[col]) {
+			return false
+		}
 	}
+	return true
 }
-func compareFloats(a, b float64) int {
-	if a > b {
-		return 1
-	} else if a < b {
-		return -1
-	} else {
-		return 0
+
+func sameValue(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
 	}
+	cmp, err := pgvalue.Compare(a, b)
+	return err == nil && cmp == 0
 }
-func compareStrings(a, b string) int { return strings.Compare(a, b) }
-func compareTimes(a, b time.Time) int {
-	if a.After(b) {
-		return 1
-	} else if a.Before(b) {
-		return -1
+
+// cursorValues returns the row's tracking values, which are bound as typed
+// parameters of the next page's query.
+func cursorValues(row map[string]any, cols []string) ([]any, error) {
+	vals := make([]any, len(cols))
+	for i, col := range cols {
+		if row[col] == nil {
+			return nil, fmt.Errorf("cannot page past NULL in tracking column %s", col)
+		}
+		vals[i] = row[col]
 	}
-	return 0
+	return vals, nil
 }
 
-func toRedisString(v any) (string, bool) {
-	switch val := v.(type) {
-	case string:
-		return val, true
-	case int64, float64:
-		return fmt.Sprintf("%v", val), true
-	case time.Time:
-		return val.Format(time.RFC3339Nano), true
-	default:
-		return "", false
-	}
+// strictOperator turns ">=" / "<=" into ">" / "<" so later pages never re-read the
+// rows at the previous page's cursor.
+func strictOperator(op string) string {
+	return strings.TrimSuffix(op, "=")
 }
@@ -0,0 +1,54 @@
+// internal/task/task_test.go
+package task
+
+import "testing"
+
+func TestSplitTrailingGroup(t *testing.T) {
+	rows := func(vals ...any) []map[string]any {
+		out := make([]map[string]any, len(vals))
+		for i, v := range vals {
+			out[i] = map[string]any{"ts": v}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		rows     []map[string]any
+		wantKeep int
+	}{
+		{"distinct", rows(int64(1), int64(2), int64(3)), 2},
+		{"trailing group", rows(int64(1), int64(2), int64(2), int64(2)), 1},
+		{"all equal", rows(int64(5), int64(5), int64(5)), 0},
+		{"trailing nulls", rows(int64(1), nil, nil), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, tail := splitTrailingGroup(tt.rows, []string{"ts"})
+			if len(keep) != tt.wantKeep || len(keep)+len(tail) != len(tt.rows) {
+				t.Fatalf("got keep=%d tail=%d, want keep=%d", len(keep), len(tail), tt.wantKeep)
+			}
+		})
+	}
+}
+
+func TestStrictOperator(t *testing.T) {
+	for op, want := range map[string]string{">": ">", ">=": ">", "<": "<", "<=": "<"} {
+		if got := strictOperator(op); got != want {
+			t.Errorf("strictOperator(%q) = %q, want %q", op, got, want)
+		}
+	}
+}
+
+func TestSplitTrailingGroupComposite(t *testing.T) {
+	rows := []map[string]any{
+		{"ts": int64(1), "id": int64(1)},
+		{"ts": int64(2), "id": int64(1)},
+		{"ts": int64(2), "id": int64(2)},
+		{"ts": int64(2), "id": int64(2)},
+	}
+	keep, tail := splitTrailingGroup(rows, []string{"ts", "id"})
+	if len(keep) != 2 || len(tail) != 2 {
+		t.Fatalf("got keep=%d tail=%d, want keep=2 tail=2", len(keep), len(tail))
+	}
+}